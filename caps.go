@@ -0,0 +1,98 @@
+package keyctl
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Bits reported by KEYCTL_CAPABILITIES.
+const (
+	caps0Capabilities       = 0x01
+	caps0PersistentKeyrings = 0x02
+	caps0DiffieHellman      = 0x04
+	caps0PublicKey          = 0x08
+	caps0BigKey             = 0x10
+	caps0Invalidate         = 0x20
+	caps0RestrictKeyring    = 0x40
+	caps0Move               = 0x80
+	caps1NsKeyringName      = 0x01
+	caps1NsKeyTag           = 0x02
+	caps1Notifications      = 0x04
+)
+
+// Keyring features supported by the running kernel, as returned by
+// Capabilities().
+type Caps struct {
+	// True if the kernel reported its capabilities directly. If false, the
+	// kernel predates KEYCTL_CAPABILITIES and the remaining fields were
+	// determined by probing.
+	Capabilities bool
+	// PersistentKeyring() is supported.
+	PersistentKeyrings bool
+	// DHCompute() is supported.
+	DiffieHellman bool
+	// PublicKeyOps (and so PrivateKey) are supported.
+	PublicKey bool
+	// "big_key" keys are supported.
+	BigKey bool
+	// Invalidate() is supported.
+	Invalidate bool
+	// Keyring.Restrict() is supported.
+	RestrictKeyring bool
+	// Move() is supported.
+	Move bool
+	// Keyring names are per user namespace.
+	NamespacedKeyringNames bool
+	// Keys can be tagged with network namespaces.
+	NamespacedKeyTags bool
+	// Watcher is supported.
+	Notifications bool
+}
+
+// Return the keyring features supported by the running kernel. On kernels
+// that predate KEYCTL_CAPABILITIES (before Linux 5.3) each feature is probed
+// for instead; namespacing and notifications are never available on such
+// kernels. Probing for "big_key" support requires a session keyring, which
+// is created if the process does not already have one.
+func Capabilities() (Caps, error) {
+	b := make([]byte, 4)
+	// Bytes beyond those known to the kernel are left zeroed.
+	_, err := keyctl_Capabilities(b)
+	if errors.Is(err, syscall.EOPNOTSUPP) {
+		return probeCapabilities(), nil
+	} else if err != nil {
+		return Caps{}, err
+	}
+	return Caps{
+		Capabilities:           b[0]&caps0Capabilities != 0,
+		PersistentKeyrings:     b[0]&caps0PersistentKeyrings != 0,
+		DiffieHellman:          b[0]&caps0DiffieHellman != 0,
+		PublicKey:              b[0]&caps0PublicKey != 0,
+		BigKey:                 b[0]&caps0BigKey != 0,
+		Invalidate:             b[0]&caps0Invalidate != 0,
+		RestrictKeyring:        b[0]&caps0RestrictKeyring != 0,
+		Move:                   b[0]&caps0Move != 0,
+		NamespacedKeyringNames: b[1]&caps1NsKeyringName != 0,
+		NamespacedKeyTags:      b[1]&caps1NsKeyTag != 0,
+		Notifications:          b[1]&caps1Notifications != 0,
+	}, nil
+}
+
+func probeCapabilities() Caps {
+	supported := func(cmd keyctlCommand) bool {
+		return !errors.Is(keyctl_Probe(cmd), syscall.EOPNOTSUPP)
+	}
+
+	// The payload is invalid for any "big_key", so nothing is ever added.
+	_, err := add_key(TypeBigKey, "", nil, int32(keySpecSessionKeyring))
+
+	return Caps{
+		PersistentKeyrings: supported(keyctlGetPersistent),
+		DiffieHellman:      supported(keyctlDHCompute),
+		PublicKey:          supported(keyctlPKeyQuery),
+		BigKey:             !errors.Is(err, syscall.ENODEV),
+		Invalidate:         supported(keyctlInvalidate),
+		RestrictKeyring:    supported(keyctlRestrictKeyring),
+		Move:               supported(keyctlMove),
+	}
+}
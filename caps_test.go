@@ -0,0 +1,27 @@
+package keyctl
+
+import (
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	caps, err := Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("capabilities: %+v", caps)
+
+	probed := probeCapabilities()
+	t.Logf("probed capabilities: %+v", probed)
+
+	if !caps.Capabilities {
+		return
+	}
+	// Features reported by the kernel must agree with probing, excepting
+	// those which cannot be probed for.
+	caps.Capabilities = false
+	caps.NamespacedKeyringNames, caps.NamespacedKeyTags, caps.Notifications = false, false, false
+	if caps != probed {
+		t.Fatalf("probed capabilities %+v do not match kernel %+v", probed, caps)
+	}
+}
@@ -0,0 +1,59 @@
+package keyctl
+
+import (
+	"syscall"
+)
+
+// Parameters for deriving a key from the shared secret computed by
+// DHCompute() using the SP800-56A key derivation function.
+type KDFParams struct {
+	// Name of the kernel hash algorithm to use (e.g. "sha256").
+	Hash string
+	// Optional OtherInfo input to the KDF.
+	OtherInfo []byte
+	// Length in bytes of the derived key. If zero, the length of the prime is
+	// used.
+	Length int
+}
+
+// Compute a Diffie-Hellman shared secret or public key in the kernel. The
+// private exponent, prime and base are read from the payloads of "user"
+// keys, as big-endian integers, so the private exponent never needs to enter
+// the process once added. The result is base ^ private mod prime, or if kdf
+// is not nil, a key derived from it.
+func DHCompute(private, prime, base *Key, kdf *KDFParams) ([]byte, error) {
+	params := &dhParams{private: int32(private.id), prime: int32(prime.id), base: int32(base.id)}
+
+	var kp *kdfParams
+	if kdf != nil {
+		var err error
+
+		kp = &kdfParams{otherinfolen: uint32(len(kdf.OtherInfo))}
+		if kp.hashname, err = syscall.BytePtrFromString(kdf.Hash); err != nil {
+			return nil, err
+		}
+		if len(kdf.OtherInfo) > 0 {
+			kp.otherinfo = &kdf.OtherInfo[0]
+		}
+	}
+
+	size := 0
+	if kdf != nil {
+		size = kdf.Length
+	}
+	if size == 0 {
+		// Ask the kernel for the length of the prime.
+		n, err := keyctl_DHCompute(params, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		size = n
+	}
+
+	b := make([]byte, size)
+	n, err := keyctl_DHCompute(params, b, kp)
+	if err != nil {
+		return nil, err
+	}
+	return b[:n], nil
+}
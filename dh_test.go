@@ -0,0 +1,55 @@
+package keyctl
+
+import (
+	"math/big"
+	"testing"
+)
+
+// RFC 3526 1536-bit MODP group, the smallest the kernel accepts.
+const testDHPrime = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1" +
+	"29024E088A67CC74020BBEA63B139B22514A08798E3404DD" +
+	"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245" +
+	"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3D" +
+	"C2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F" +
+	"83655D23DCA3AD961C62F356208552BB9ED529077096966D" +
+	"670C354E4ABC9804F1746C08CA237327FFFFFFFFFFFFFFFF"
+
+func TestDHCompute(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "dhring", t)
+	defer UnlinkKeyring(ring)
+
+	p, _ := new(big.Int).SetString(testDHPrime, 16)
+	g := big.NewInt(2)
+	x := new(big.Int).SetBytes(helperRandBlock(32))
+
+	private, err := ring.Add("dh-private", x.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	prime, err := ring.Add("dh-prime", p.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := ring.Add("dh-base", g.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := DHCompute(private, prime, base, nil)
+	if err != nil {
+		helperSkipUnsupported(t, err)
+		t.Fatal(err)
+	}
+
+	expected := new(big.Int).Exp(g, x, p).FillBytes(make([]byte, len(p.Bytes())))
+	helperCmp(t, pub, expected)
+
+	derived, err := DHCompute(private, prime, base, &KDFParams{Hash: "sha256", OtherInfo: []byte("keyctl"), Length: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(derived) != 32 {
+		t.Fatalf("expected 32 byte derived key, got %d", len(derived))
+	}
+}
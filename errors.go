@@ -0,0 +1,93 @@
+package keyctl
+
+import (
+	"errors"
+	"strconv"
+	"syscall"
+)
+
+var (
+	// Error returned when operating on a key or keyring that has been revoked.
+	ErrKeyRevoked = errors.New("keyctl key has been revoked")
+	// Error returned when a requested key does not exist and could not be
+	// constructed.
+	ErrKeyNotFound = errors.New("keyctl key not found")
+	// Error returned when a requested key has expired.
+	ErrKeyExpired = errors.New("keyctl key has expired")
+	// Error returned when a requested key was negatively instantiated
+	// (rejected) by the program constructing it.
+	ErrKeyRejected = errors.New("keyctl key was rejected")
+	// Error returned when adding or updating a key would exceed the owner's
+	// key quota.
+	ErrQuotaExceeded = errors.New("keyctl key quota exceeded")
+	// Error returned when the caller lacks permission for an operation on a
+	// key or keyring.
+	ErrPermissionDenied = errors.New("keyctl permission denied")
+	// Error returned when the kernel does not support an operation or key
+	// type.
+	ErrNotSupported = errors.New("keyctl operation not supported")
+)
+
+// KeyctlError is returned by every operation that fails in the kernel. It
+// records the operation attempted, the key or keyring it was performed on and
+// the underlying error, normally a syscall.Errno.
+//
+// KeyctlError matches the sentinel errors of this package with errors.Is(),
+// for example:
+//
+//	if _, err := ring.Search("name"); errors.Is(err, keyctl.ErrKeyNotFound) {
+//		...
+//	}
+type KeyctlError struct {
+	Op  keyctlCommand
+	Id  int32
+	Err error
+}
+
+func newKeyctlError(op keyctlCommand, id keyId, errno syscall.Errno) error {
+	return &KeyctlError{Op: op, Id: int32(id), Err: errno}
+}
+
+func (e *KeyctlError) Error() string {
+	return e.Op.String() + " " + strconv.Itoa(int(e.Id)) + ": " + e.Err.Error()
+}
+
+// Returns the underlying error.
+func (e *KeyctlError) Unwrap() error {
+	return e.Err
+}
+
+// Reports whether the error matches one of the sentinel errors of this
+// package.
+func (e *KeyctlError) Is(target error) bool {
+	errno, ok := e.Err.(syscall.Errno)
+	if !ok {
+		return false
+	}
+
+	switch target {
+	case ErrKeyNotFound:
+		return errno == syscall.ENOKEY
+	case ErrKeyExpired:
+		return errno == syscall.EKEYEXPIRED
+	case ErrKeyRevoked:
+		return errno == syscall.EKEYREVOKED
+	case ErrKeyRejected:
+		return errno == syscall.EKEYREJECTED
+	case ErrQuotaExceeded:
+		return errno == syscall.EDQUOT
+	case ErrPermissionDenied:
+		return errno == syscall.EACCES || errno == syscall.EPERM
+	case ErrNotSupported:
+		return errno == syscall.EOPNOTSUPP || errno == syscall.ENODEV
+	case ErrKeyUnreadable:
+		return e.Op == keyctlRead && errno == syscall.EOPNOTSUPP
+	case ErrSessionToParentDenied:
+		return e.Op == keyctlSessionToParent && errno == syscall.EPERM
+	case ErrPersistentKeyringsUnsupported:
+		return e.Op == keyctlGetPersistent && errno == syscall.EOPNOTSUPP
+	case ErrBadSignature:
+		return e.Op == keyctlPKeyVerify && (errno == syscall.EKEYREJECTED || errno == syscall.EBADMSG)
+	}
+	return false
+}
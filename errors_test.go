@@ -0,0 +1,57 @@
+package keyctl
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestKeyctlErrorIs(t *testing.T) {
+	cases := []struct {
+		err    *KeyctlError
+		target error
+	}{
+		{&KeyctlError{Op: keyctlSearch, Err: syscall.ENOKEY}, ErrKeyNotFound},
+		{&KeyctlError{Op: keyctlRead, Err: syscall.EKEYEXPIRED}, ErrKeyExpired},
+		{&KeyctlError{Op: keyctlRead, Err: syscall.EKEYREVOKED}, ErrKeyRevoked},
+		{&KeyctlError{Op: keyctlRequestKey, Err: syscall.EKEYREJECTED}, ErrKeyRejected},
+		{&KeyctlError{Op: keyctlAddKey, Err: syscall.EDQUOT}, ErrQuotaExceeded},
+		{&KeyctlError{Op: keyctlLink, Err: syscall.EACCES}, ErrPermissionDenied},
+		{&KeyctlError{Op: keyctlSetPerm, Err: syscall.EPERM}, ErrPermissionDenied},
+		{&KeyctlError{Op: keyctlAddKey, Err: syscall.ENODEV}, ErrNotSupported},
+		{&KeyctlError{Op: keyctlRead, Err: syscall.EOPNOTSUPP}, ErrKeyUnreadable},
+		{&KeyctlError{Op: keyctlPKeyVerify, Err: syscall.EBADMSG}, ErrBadSignature},
+	}
+
+	for _, c := range cases {
+		if !errors.Is(c.err, c.target) {
+			t.Errorf("%v: expected to match %v", c.err, c.target)
+		}
+		if !errors.Is(c.err, c.err.Err) {
+			t.Errorf("%v: expected to match %v", c.err, c.err.Err)
+		}
+	}
+
+	if err := (&KeyctlError{Op: keyctlMove, Err: syscall.EOPNOTSUPP}); errors.Is(err, ErrKeyUnreadable) {
+		t.Errorf("%v: unexpected match with %v", err, ErrKeyUnreadable)
+	}
+}
+
+func TestKeyctlErrorSearch(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "errring", t)
+	defer UnlinkKeyring(ring)
+
+	_, err := ring.Search("missing")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+
+	var kerr *KeyctlError
+	if !errors.As(err, &kerr) {
+		t.Fatalf("expected *KeyctlError, got %T", err)
+	}
+	if kerr.Op != keyctlSearch || kerr.Id != ring.Id() || kerr.Err != syscall.ENOKEY {
+		t.Fatalf("unexpected error fields: %+v", kerr)
+	}
+	t.Log(err)
+}
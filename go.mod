@@ -1 +1,3 @@
 module github.com/jsipprell/keyctl
+
+go 1.15
@@ -0,0 +1,59 @@
+package keyctl
+
+import (
+	"syscall"
+)
+
+// The functions below are used by programs constructing keys on behalf of
+// the kernel, typically as invoked by /sbin/request-key. The key under
+// construction is identified by the serial number passed to such programs
+// and authority over it must have been assumed by calling AssumeAuthority()
+// beforehand. The github.com/jsipprell/keyctl/requestkey package provides a
+// higher level interface on top of these.
+
+// Assume the authority to instantiate the key under construction with the
+// given serial number. Passing 0 relinquishes any authority held.
+func AssumeAuthority(key int32) error {
+	return keyctl_AssumeAuthority(keyId(key))
+}
+
+// Return the callout info passed to request_key(2) for the key whose
+// construction authority has been assumed.
+func CalloutInfo() ([]byte, error) {
+	k := &Key{id: keySpecReqKeyAuthKey}
+	return k.Get()
+}
+
+// Instantiate a key under construction with the given payload. If dest is
+// not nil the key is also linked to it.
+func Instantiate(key int32, payload []byte, dest Keyring) error {
+	return keyctl_Instantiate(keyId(key), payload, ringId(dest))
+}
+
+// Instantiate a key under construction with a payload assembled from
+// multiple buffers, avoiding the need to join them in userspace. If dest is
+// not nil the key is also linked to it.
+func InstantiateIov(key int32, payload [][]byte, dest Keyring) error {
+	return keyctl_InstantiateIov(keyId(key), payload, ringId(dest))
+}
+
+// Negatively instantiate a key under construction. Requests for the key fail
+// with ErrKeyNotFound until nsecs seconds have passed. If dest is not nil the
+// negative key is also linked to it.
+func Negate(key int32, nsecs uint, dest Keyring) error {
+	return keyctl_Negate(keyId(key), nsecs, ringId(dest))
+}
+
+// Negatively instantiate a key under construction, causing requests for it to
+// fail with the given error until nsecs seconds have passed. If dest is not
+// nil the rejected key is also linked to it.
+func Reject(key int32, nsecs uint, reason syscall.Errno, dest Keyring) error {
+	return keyctl_Reject(keyId(key), nsecs, reason, ringId(dest))
+}
+
+func ringId(ring Keyring) keyId {
+	if ring == nil {
+		return 0
+	}
+	return keyId(ring.Id())
+}
@@ -0,0 +1,41 @@
+package keyctl
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+// Constructing keys needs authority that only the kernel can grant to a
+// request-key helper, so only the refusals can be tested here; see the
+// requestkey package for tests of how results are mapped to these calls.
+func TestInstantiateWithoutAuthority(t *testing.T) {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ring.Add("instantiate-test", []byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Unlink()
+
+	id := key.Id()
+	for name, err := range map[string]error{
+		"Instantiate":    Instantiate(id, []byte("payload"), nil),
+		"InstantiateIov": InstantiateIov(id, [][]byte{[]byte("pay"), []byte("load")}, ring),
+		"Negate":         Negate(id, 10, nil),
+		"Reject":         Reject(id, 10, syscall.EKEYREJECTED, ring),
+	} {
+		if !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: expected ErrPermissionDenied, got %v", name, err)
+		}
+	}
+
+	if err = AssumeAuthority(id); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("AssumeAuthority: expected ErrKeyNotFound, got %v", err)
+	}
+	if _, err = CalloutInfo(); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("CalloutInfo: expected ErrKeyNotFound, got %v", err)
+	}
+}
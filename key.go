@@ -1,16 +1,32 @@
 package keyctl
 
 import (
+	"errors"
+	"sync"
+	"syscall"
 	"time"
 )
 
+// Error returned by Get() when the key's payload cannot be read back into
+// userspace, as is the case for "logon" keys.
+var ErrKeyUnreadable = errors.New("keyctl key payload is not readable")
+
 // Represents a single key linked to one or more kernel keyrings.
+//
+// A *Key is safe for concurrent use by multiple goroutines, with the
+// exception of the Name field which must not be modified while the key is
+// shared.
 type Key struct {
 	Name string
 
-	id, ring keyId
+	id  keyId
+	typ string
+
+	mu       sync.Mutex
+	ring     keyId
 	size     int
 	ttl      time.Duration
+	deadline time.Time
 }
 
 func (k *Key) private() {}
@@ -20,13 +36,115 @@ func (k *Key) Id() int32 {
 	return int32(k.id)
 }
 
+// Returns the kernel key type of a key (e.g. "user", "logon", "big_key").
+func (k *Key) Type() string {
+	if k.typ == "" {
+		return TypeUser
+	}
+	return k.typ
+}
+
 // To expire a key automatically after some period of time call this method.
+// A timeout of zero removes any expiry.
 func (k *Key) ExpireAfter(nsecs uint) error {
-	k.ttl = time.Duration(nsecs) * time.Second
+	return k.setExpiry(nsecs, time.Time{})
+}
+
+// Expire a key automatically once a duration has passed. The kernel tracks
+// expiry in whole seconds, so the duration is rounded up to the next second.
+// As with ExpireAfter(0), a zero or negative duration removes any expiry.
+func (k *Key) ExpireIn(d time.Duration) error {
+	return k.setExpiry(expirySeconds(d), time.Time{})
+}
+
+// Expire a key automatically at a specific time. Like ExpireIn(), the
+// deadline is rounded up to the next second and one in the past expires the
+// key one second from now.
+func (k *Key) ExpireAt(t time.Time) error {
+	return k.setExpiry(timeoutSeconds(time.Until(t)), t)
+}
+
+// Remove any expiry from a key so that it persists until unlinked.
+func (k *Key) ClearExpiry() error {
+	return k.setExpiry(0, time.Time{})
+}
 
+// The lock is held while the kernel is told so that the expiry remembered
+// for Set() always matches the last one applied.
+func (k *Key) setExpiry(nsecs uint, deadline time.Time) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.ttl, k.deadline = time.Duration(nsecs)*time.Second, deadline
 	return keyctl_SetTimeout(k.id, nsecs)
 }
 
+// Returns the time at which the kernel will expire the key, or the zero time
+// if the key does not expire. The expiry is read from /proc/keys, which
+// reports the time remaining truncated to the largest whole unit (seconds,
+// minutes, hours, days or weeks), so long expiry times are approximate.
+func (k *Key) ExpiresAt() (time.Time, error) {
+	id, err := resolveKeyId(k.id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return expiresAt(id)
+}
+
+// Returns the time left before the kernel expires the key, or 0 if the key
+// does not expire. ErrKeyExpired is returned if the key has already expired.
+// See ExpiresAt() for the precision of the result.
+func (k *Key) Remaining() (time.Duration, error) {
+	return remaining(k.ExpiresAt())
+}
+
+func expiresAt(id keyId) (time.Time, error) {
+	pk, err := readProcKey(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return pk.expires, nil
+}
+
+func remaining(t time.Time, err error) (time.Duration, error) {
+	if err != nil || t.IsZero() {
+		return 0, err
+	}
+	if d := time.Until(t); d > 0 {
+		return d, nil
+	}
+	return 0, ErrKeyExpired
+}
+
+// Reapply the expiry last set on the key, which is lost when its payload is
+// updated. Must be called with the lock held.
+func (k *Key) resetExpiry() error {
+	switch {
+	case !k.deadline.IsZero():
+		return keyctl_SetTimeout(k.id, timeoutSeconds(time.Until(k.deadline)))
+	case k.ttl > 0:
+		return keyctl_SetTimeout(k.id, uint(k.ttl/time.Second))
+	}
+	return nil
+}
+
+// Like timeoutSeconds(), but returning 0 for a zero or negative duration.
+func expirySeconds(d time.Duration) uint {
+	if d <= 0 {
+		return 0
+	}
+	return timeoutSeconds(d)
+}
+
+// Convert a duration to the whole number of seconds passed to the kernel,
+// rounding up and never returning 0, which would clear the expiry.
+func timeoutSeconds(d time.Duration) uint {
+	if d < time.Second {
+		return 1
+	}
+	return uint((d + time.Second - 1) / time.Second)
+}
+
 // Return information about a key.
 func (k *Key) Info() (Info, error) {
 	return getInfo(k.id)
@@ -40,11 +158,28 @@ func (k *Key) Get() ([]byte, error) {
 		sizeRead int
 	)
 
-	if k.size == 0 {
-		k.size = 512
+	if k.typ == TypeLogon {
+		// Reading a "logon" key always fails, don't bother asking.
+		return nil, newKeyctlError(keyctlRead, k.id, syscall.EOPNOTSUPP)
 	}
 
+	k.mu.Lock()
 	size := k.size
+	k.mu.Unlock()
+
+	if size == 0 && k.typ == TypeBigKey {
+		// big_key payloads can be large and expensive for the kernel to
+		// produce, so ask for the exact size up front rather than guessing.
+		r1, err := keyctl_Read(k.id, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		size = int(r1)
+	}
+
+	if size == 0 {
+		size = 512
+	}
 
 	b = make([]byte, int(size))
 	sizeRead = size + 1
@@ -58,24 +193,49 @@ func (k *Key) Get() ([]byte, error) {
 			b = make([]byte, sizeRead)
 			size = sizeRead
 			sizeRead = size + 1
-		} else {
-			k.size = sizeRead
 		}
 	}
-	return b[:k.size], err
+
+	// Remember the size so the next read can be done in a single call.
+	k.mu.Lock()
+	k.size = sizeRead
+	k.mu.Unlock()
+	return b[:sizeRead], err
 }
 
 // Set the key's value from a bytes slice. Expiration, if active, is reset by calling this method.
 func (k *Key) Set(b []byte) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
 	err := updateKey(k.id, b)
-	if err == nil && k.ttl > 0 {
-		err = k.ExpireAfter(uint(k.ttl.Seconds()))
+	if err == nil {
+		err = k.resetExpiry()
 	}
 	return err
 }
 
 // Unlink a key from the keyring it was loaded from (or added to). If the key
-// is not linked to any other keyrings, it is destroyed.
+// is not linked to any other keyrings, it is destroyed. Keys not loaded from a
+// known keyring, such as those returned by RequestKey() without a
+// destination, cannot be unlinked this way.
 func (k *Key) Unlink() error {
-	return keyctl_Unlink(k.id, k.ring)
+	k.mu.Lock()
+	ring := k.ring
+	k.mu.Unlock()
+
+	return keyctl_Unlink(k.id, ring)
+}
+
+// Revoke a key, preventing any further access to it regardless of how many
+// keyrings it is linked to. Subsequent attempts to use the key fail with
+// ErrKeyRevoked.
+func (k *Key) Revoke() error {
+	return Revoke(k)
+}
+
+// Invalidate a key, immediately unlinking it from every keyring it is linked
+// to and scheduling it for destruction.
+func (k *Key) Invalidate() error {
+	return Invalidate(k)
 }
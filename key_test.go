@@ -1,7 +1,9 @@
 package keyctl
 
 import (
+	"errors"
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 )
@@ -98,3 +100,196 @@ func TestRandomKey700(t *testing.T) {
 	}
 	t.Logf("key %v expired after five seconds", id.Id())
 }
+
+func TestRevokeKey(t *testing.T) {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ring.Add("revoke-test", []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Unlink()
+
+	if err = key.Revoke(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = key.Get(); !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("expected ErrKeyRevoked from Get, got %v", err)
+	}
+	if _, err = key.Info(); !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("expected ErrKeyRevoked from Info, got %v", err)
+	}
+	if _, err = ring.Search("revoke-test"); !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("expected ErrKeyRevoked from Search, got %v", err)
+	}
+}
+
+func TestRevokeKeyring(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "revokering", t)
+	defer UnlinkKeyring(ring)
+
+	if _, err := ring.Add("revoke-child", []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Revoke(ring); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.Search("revoke-child"); !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("expected ErrKeyRevoked searching revoked keyring, got %v", err)
+	}
+}
+
+func TestKeyExpireIn(t *testing.T) {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ring.Add("expire-in", []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Unlink()
+
+	if d, err := key.Remaining(); err != nil || d != 0 {
+		t.Fatalf("expected no expiry on new key, got %v (%v)", d, err)
+	}
+
+	if err = key.ExpireIn(90 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// Look the key up again so that nothing cached locally is used.
+	found, err := ring.Search("expire-in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// /proc/keys reports 90 seconds as "1m".
+	if d, err := found.Remaining(); err != nil || d < 55*time.Second || d > 90*time.Second {
+		t.Fatalf("expected about a minute remaining, got %v (%v)", d, err)
+	}
+
+	// Updating the payload keeps the expiry.
+	if err = key.Set([]byte{4, 5, 6}); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := found.Remaining(); err != nil || d == 0 {
+		t.Fatalf("expected expiry to survive Set, got %v (%v)", d, err)
+	}
+
+	if err = key.ClearExpiry(); err != nil {
+		t.Fatal(err)
+	}
+	if at, err := found.ExpiresAt(); err != nil || !at.IsZero() {
+		t.Fatalf("expected no expiry after ClearExpiry, got %v (%v)", at, err)
+	}
+
+	// Sub-second durations are rounded up, and the rounded timeout is what
+	// Set() reapplies.
+	if err = key.ExpireIn(500 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err = key.Set([]byte{7, 8, 9}); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := found.Remaining(); err != nil || d == 0 || d > time.Second {
+		t.Fatalf("expected rounded expiry to survive Set, got %v (%v)", d, err)
+	}
+
+	for _, d := range []time.Duration{0, -time.Second} {
+		if err = key.ExpireIn(d); err != nil {
+			t.Fatal(err)
+		}
+		if at, err := found.ExpiresAt(); err != nil || !at.IsZero() {
+			t.Fatalf("expected ExpireIn(%v) to remove the expiry, got %v (%v)", d, at, err)
+		}
+	}
+}
+
+func TestKeyExpireAt(t *testing.T) {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ring.Add("expire-at", []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Unlink()
+
+	deadline := time.Now().Add(30 * time.Second)
+	if err = key.ExpireAt(deadline); err != nil {
+		t.Fatal(err)
+	}
+	at, err := key.ExpiresAt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := at.Sub(deadline); d < -2*time.Second || d > 2*time.Second {
+		t.Fatalf("expected expiry near %v, got %v", deadline, at)
+	}
+}
+
+func TestTimeoutSeconds(t *testing.T) {
+	cases := map[time.Duration]uint{
+		-time.Second:            1,
+		0:                       1,
+		time.Millisecond:        1,
+		time.Second:             1,
+		1500 * time.Millisecond: 2,
+		time.Minute:             60,
+	}
+	for d, expected := range cases {
+		if n := timeoutSeconds(d); n != expected {
+			t.Errorf("timeoutSeconds(%v) = %d, expected %d", d, n, expected)
+		}
+	}
+}
+
+func TestKeyConcurrentAccess(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "concurrentkeys", t)
+	defer UnlinkKeyring(ring)
+
+	key, err := ring.Add("concurrent", helperRandBlock(600))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				var err error
+				switch (i + j) % 4 {
+				case 0:
+					_, err = key.Get()
+				case 1:
+					err = key.Set(helperRandBlock(100 * (j%8 + 1)))
+				case 2:
+					err = key.ExpireAfter(uint(60 + j))
+				case 3:
+					err = key.ExpireIn(time.Duration(j+60) * time.Second)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if _, err = Move(key, ring, ring, false); err != nil {
+		t.Fatal(err)
+	}
+	if err = key.Unlink(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -7,6 +7,30 @@
 // A Go interface to linux kernel keyrings (keyctl interface)
 package keyctl
 
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Error returned by SessionToParent() when the kernel refuses to replace the
+// parent's session keyring, typically because the parent runs with different
+// credentials, is multi-threaded or is init.
+var ErrSessionToParentDenied = errors.New("keyctl parent session keyring cannot be replaced")
+
+// Error returned by JoinSessionKeyring() when given an empty name. Use
+// JoinAnonymousSessionKeyring() to join a new anonymous session keyring.
+var ErrSessionNameRequired = errors.New("keyctl session keyring name required, use JoinAnonymousSessionKeyring")
+
+// Error returned by PersistentKeyring() when the kernel was built without
+// persistent keyring support (CONFIG_PERSISTENT_KEYRINGS).
+var ErrPersistentKeyringsUnsupported = errors.New("keyctl persistent keyrings not supported by kernel")
+
 // All Keys and Keyrings have unique 32-bit serial number identifiers.
 type Id interface {
 	Id() int32
@@ -15,12 +39,20 @@ type Id interface {
 	private()
 }
 
-// Basic interface to a linux keyctl keyring.
+// Basic interface to a linux keyctl keyring. Keyrings returned by this
+// package are safe for concurrent use by multiple goroutines.
 type Keyring interface {
 	Id
 	Add(string, []byte) (*Key, error)
+	AddWithType(string, string, []byte) (*Key, error)
 	Search(string) (*Key, error)
+	SearchWithType(string, string) (*Key, error)
 	SetDefaultTimeout(uint)
+	SetDefaultExpiry(time.Duration)
+	ExpiresAt() (time.Time, error)
+	Remaining() (time.Duration, error)
+	Clear() error
+	Restrict(string, string) error
 }
 
 // Named keyrings are user-created keyrings linked to a parent keyring. The
@@ -30,18 +62,28 @@ type Keyring interface {
 type NamedKeyring interface {
 	Keyring
 	Name() string
+	// Returns a specifier for the keyring that can be passed to ParseSpec()
+	// or Resolve(), e.g. "@s/app/db".
+	Path() string
 }
 
 type keyring struct {
-	id         keyId
-	defaultTtl uint
+	id keyId
+
+	// mu also guards the fields of a namedKeyring embedding the keyring.
+	mu          sync.Mutex
+	defaultTtl  uint
+	restricted  bool
+	restriction string
 }
 
 type namedKeyring struct {
 	*keyring
-	parent keyId
-	name   string // for non-anonymous keyrings
-	ttl    uint
+	name string // for non-anonymous keyrings
+
+	parent     keyId
+	parentPath string
+	ttl        uint
 }
 
 func (kr *keyring) private() {}
@@ -53,7 +95,13 @@ func (kr *keyring) Id() int32 {
 
 // Returns information about a keyring.
 func (kr *keyring) Info() (Info, error) {
-	return getInfo(kr.id)
+	i, err := getInfo(kr.id)
+	if err == nil {
+		kr.mu.Lock()
+		i.Restricted, i.Restriction = kr.restricted, kr.restriction
+		kr.mu.Unlock()
+	}
+	return i, err
 }
 
 // Return the name of a NamedKeyring that was set when the keyring was created
@@ -62,19 +110,71 @@ func (kr *namedKeyring) Name() string {
 	return kr.name
 }
 
+// Return the path of a NamedKeyring, formed from the path of the keyring it
+// was created in, opened from or moved to. If that is not known, a "%:name"
+// specifier is returned instead.
+func (kr *namedKeyring) Path() string {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if kr.parentPath == "" {
+		return "%:" + kr.name
+	}
+	return kr.parentPath + "/" + kr.name
+}
+
 // Set a default timeout, in seconds, after which newly added keys will be
 // destroyed.
 func (kr *keyring) SetDefaultTimeout(nsecs uint) {
+	kr.mu.Lock()
 	kr.defaultTtl = nsecs
+	kr.mu.Unlock()
+}
+
+// Set a default duration after which newly added keys will be destroyed. As
+// with Key.ExpireIn() the duration is rounded up to whole seconds; a zero or
+// negative duration means newly added keys do not expire.
+func (kr *keyring) SetDefaultExpiry(d time.Duration) {
+	kr.SetDefaultTimeout(expirySeconds(d))
+}
+
+// Returns the time at which the kernel will expire the keyring, or the zero
+// time if the keyring does not expire. See Key.ExpiresAt() for the precision
+// of the result.
+func (kr *keyring) ExpiresAt() (time.Time, error) {
+	id, err := resolveKeyId(kr.id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return expiresAt(id)
+}
+
+// Returns the time left before the kernel expires the keyring, or 0 if the
+// keyring does not expire. ErrKeyExpired is returned if the keyring has
+// already expired.
+func (kr *keyring) Remaining() (time.Duration, error) {
+	return remaining(kr.ExpiresAt())
 }
 
 // Add a new key to a keyring. The key can be searched for later by name.
 func (kr *keyring) Add(name string, key []byte) (*Key, error) {
-	r, err := add_key("user", name, key, int32(kr.id))
+	return kr.AddWithType(TypeUser, name, key)
+}
+
+// Add a new key of a specific kernel key type to a keyring. The key can be
+// searched for later by type and name.
+func (kr *keyring) AddWithType(keyType, name string, key []byte) (*Key, error) {
+	if err := validateName(keyType, name); err != nil {
+		return nil, err
+	}
+	r, err := add_key(keyType, name, key, int32(kr.id))
 	if err == nil {
-		key := &Key{Name: name, id: keyId(r), ring: kr.id}
-		if kr.defaultTtl != 0 {
-			err = key.ExpireAfter(kr.defaultTtl)
+		key := &Key{Name: name, id: keyId(r), ring: kr.id, typ: keyType}
+		kr.mu.Lock()
+		ttl := kr.defaultTtl
+		kr.mu.Unlock()
+		if ttl != 0 {
+			err = key.ExpireAfter(ttl)
 		}
 		return key, err
 	}
@@ -86,18 +186,135 @@ func (kr *keyring) Add(name string, key []byte) (*Key, error) {
 // one. The key, if found, is linked to the top keyring that Search() was called
 // from.
 func (kr *keyring) Search(name string) (*Key, error) {
-	id, err := searchKeyring(kr.id, name, "user")
+	return kr.SearchWithType(TypeUser, name)
+}
+
+// Search for a key of a specific kernel key type by name. Like Search(), child
+// keyrings are also searched.
+func (kr *keyring) SearchWithType(keyType, name string) (*Key, error) {
+	if err := validateName(keyType, name); err != nil {
+		return nil, err
+	}
+	id, err := searchKeyring(kr.id, name, keyType)
 	if err == nil {
-		return &Key{Name: name, id: id, ring: kr.id}, nil
+		return &Key{Name: name, id: id, ring: kr.id, typ: keyType}, nil
 	}
 	return nil, err
 }
 
-// Return the current login session keyring
+// Unlink all keys and keyrings from a keyring. Keys which are not linked to
+// any other keyring are destroyed.
+func (kr *keyring) Clear() error {
+	return keyctl_Clear(kr.id)
+}
+
+// Restrict which keys may be linked to a keyring. The restriction is
+// interpreted by the given key type, for example Restrict("asymmetric",
+// "builtin_trusted"). If keyType is empty, all further links to the keyring
+// are blocked. Once set, a restriction cannot be changed or removed.
+func (kr *keyring) Restrict(keyType, restriction string) error {
+	err := restrictKeyring(kr.id, keyType, restriction)
+	if err == nil {
+		kr.mu.Lock()
+		kr.restricted = true
+		if keyType != "" {
+			kr.restriction = keyType + " " + restriction
+		}
+		kr.mu.Unlock()
+	}
+	return err
+}
+
+// Restrict a keyring to "asymmetric" keys signed by the given key or by any
+// key in the given keyring. If chain is true, keys signed by keys already
+// linked to the restricted keyring are also permitted. A nil signer together
+// with chain permits only keys signed by keys in the restricted keyring.
+func RestrictKeyOrKeyring(kr Keyring, signer Id, chain bool) error {
+	var id int32
+
+	if signer != nil {
+		id = signer.Id()
+	}
+	restriction := "key_or_keyring:" + strconv.Itoa(int(id))
+	if chain {
+		restriction += ":chain"
+	}
+	return kr.Restrict(TypeAsymmetric, restriction)
+}
+
+// Restrict a keyring to "asymmetric" keys signed by a key in the kernel's
+// builtin trusted keyring.
+func RestrictBuiltinTrusted(kr Keyring) error {
+	return kr.Restrict(TypeAsymmetric, "builtin_trusted")
+}
+
+// Block all further links to a keyring, preventing any keys from being added.
+func RestrictAllLinks(kr Keyring) error {
+	return kr.Restrict("", "")
+}
+
+// Return the current login session keyring. The kernel tracks session
+// keyrings per thread, so after JoinSessionKeyring() the result depends on
+// which goroutine calls this.
 func SessionKeyring() (Keyring, error) {
 	return newKeyring(keySpecSessionKeyring)
 }
 
+// Join the session keyring with the given name, creating it if it does not
+// already exist, and install it as the session keyring of the calling thread.
+//
+// As the kernel only changes the session of the calling OS thread, the
+// calling goroutine is locked to its current thread (see
+// runtime.LockOSThread) and remains locked after this returns. Subsequent
+// calls to SessionKeyring() from the same goroutine will then refer to the
+// joined keyring. If the goroutine exits without unlocking the thread, the
+// thread is terminated rather than returned to the scheduler, so the new
+// session never leaks to other goroutines.
+func JoinSessionKeyring(name string) (NamedKeyring, error) {
+	if name == "" {
+		return nil, ErrSessionNameRequired
+	}
+	id, err := joinSession(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &namedKeyring{
+		keyring: &keyring{id: id},
+		name:    name,
+	}, nil
+}
+
+// Create a new anonymous session keyring and install it as the session
+// keyring of the calling thread. The same thread locking caveats described
+// for JoinSessionKeyring() apply.
+func JoinAnonymousSessionKeyring() (Keyring, error) {
+	id, err := joinSession("")
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyring{id: id}, nil
+}
+
+// Replace the session keyring of the parent process with the session keyring
+// of the calling thread, in the manner of "keyctl session_to_parent". The
+// change takes effect when the parent next returns from the kernel to
+// userspace. This is normally used after JoinSessionKeyring() or
+// JoinAnonymousSessionKeyring() and must be called from the same goroutine.
+func SessionToParent() error {
+	return sessionToParent()
+}
+
+func joinSession(name string) (keyId, error) {
+	runtime.LockOSThread()
+	id, err := joinSessionKeyring(name)
+	if err != nil {
+		runtime.UnlockOSThread()
+	}
+	return id, err
+}
+
 // Return the current user-session keyring (part of session, but private to
 // current user)
 func UserSessionKeyring() (Keyring, error) {
@@ -108,6 +325,24 @@ func UserKeyring() (Keyring, error) {
 	return newKeyring(keySpecUserKeyring)
 }
 
+// Return the persistent keyring of a user, linking it to another keyring. The
+// persistent keyring survives between logins of the user and is only
+// destroyed once it has gone unused for a (system configurable) period of
+// time. A uid of -1 refers to the calling user; accessing the persistent
+// keyring of any other user requires CAP_SETUID. The kernel insists on
+// linking the persistent keyring somewhere, so link must not be nil.
+func PersistentKeyring(uid int, link Keyring) (Keyring, error) {
+	if link == nil {
+		return nil, newKeyctlError(keyctlGetPersistent, 0, syscall.EINVAL)
+	}
+	id, err := getPersistent(uid, keyId(link.Id()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyring{id: id}, nil
+}
+
 // Return the current group keyring.
 func GroupKeyring() (Keyring, error) {
 	return newKeyring(keySpecGroupKeyring)
@@ -127,7 +362,8 @@ func ProcessKeyring() (Keyring, error) {
 // one of those returned by SessionKeyring(), UserSessionKeyring() and friends
 // or it may be an existing named-keyring. When searching is performed, all
 // keyrings form a hierarchy and are searched top-down. If the keyring already
-// exists it will be destroyed and a new one with the same name created. Named
+// exists it will be destroyed and a new one with the same name created (use
+// OpenOrCreateKeyring() to keep an existing keyring instead). Named
 // sub-keyrings inherit their initial ttl (if set) from the parent but can
 // outlive the parent as the timer is restarted at creation.
 func CreateKeyring(parent Keyring, name string) (NamedKeyring, error) {
@@ -140,13 +376,16 @@ func CreateKeyring(parent Keyring, name string) (NamedKeyring, error) {
 	}
 
 	if pkr, ok := parent.(*namedKeyring); ok {
+		pkr.mu.Lock()
 		ttl = pkr.ttl
+		pkr.mu.Unlock()
 	}
 	ring := &namedKeyring{
-		keyring: kr,
-		parent:  parentId,
-		name:    name,
-		ttl:     ttl,
+		keyring:    kr,
+		parent:     parentId,
+		parentPath: pathOf(parent),
+		name:       name,
+		ttl:        ttl,
 	}
 
 	if ttl > 0 {
@@ -166,23 +405,206 @@ func OpenKeyring(parent Keyring, name string) (NamedKeyring, error) {
 	}
 
 	return &namedKeyring{
-		keyring: &keyring{id: id},
-		parent:  parentId,
-		name:    name,
+		keyring:    &keyring{id: id},
+		parent:     parentId,
+		parentPath: pathOf(parent),
+		name:       name,
+	}, nil
+}
+
+// Options applied by OpenOrCreateKeyring() when it creates a keyring.
+type KeyringOptions struct {
+	// Time to live in seconds, see SetKeyringTTL(). If zero, the ttl of a
+	// named parent is inherited as with CreateKeyring().
+	TTL uint
+	// Permissions of the keyring, see SetPerm(). If zero, the kernel's
+	// default permissions are left in place.
+	Perm KeyPerm
+}
+
+// Open the keyring with the given name linked directly to a parent keyring,
+// creating it only if it does not exist. Unlike CreateKeyring() an existing
+// keyring is never replaced, and unlike OpenKeyring() keyrings linked below
+// the parent are not searched. The options, which may be nil, are only
+// applied when the keyring is created.
+//
+// A new keyring is built inside a temporary staging keyring in the parent,
+// with its ttl and permissions already set, and then moved into the parent
+// only if no keyring of the same name has appeared there in the meantime, so
+// processes racing to create the same keyring all end up sharing one. The
+// permissions given must therefore leave the possessor able to link the
+// keyring. On kernels without KEYCTL_MOVE (before Linux 5.1) the new keyring
+// is linked into the parent instead, leaving a small window in which a
+// keyring created concurrently by another process can be replaced.
+//
+// If the parent holds a keyring of the same name that the caller cannot
+// view, such as one without view permission or one that has expired but not
+// yet been garbage collected, it can neither be opened nor replaced and an
+// error matching syscall.EEXIST is returned.
+func OpenOrCreateKeyring(parent Keyring, name string, opts *KeyringOptions) (NamedKeyring, error) {
+	parentId := keyId(parent.Id())
+	id, err := findKeyring(parentId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if id == 0 {
+		var ring *namedKeyring
+		ring, err = createKeyringExclusive(parent, name, opts)
+		if !errors.Is(err, syscall.EEXIST) {
+			return ring, err
+		}
+		// Another process created the keyring first, open theirs.
+		if id, _ = findKeyring(parentId, name); id == 0 {
+			return nil, err
+		}
+	}
+
+	return &namedKeyring{
+		keyring:    &keyring{id: id},
+		parent:     parentId,
+		parentPath: pathOf(parent),
+		name:       name,
 	}, nil
 }
 
+// Create a keyring in a parent keyring unless one with the same name is
+// already there, in which case an error matching syscall.EEXIST is returned.
+func createKeyringExclusive(parent Keyring, name string, opts *KeyringOptions) (*namedKeyring, error) {
+	var (
+		ttl  uint
+		perm KeyPerm
+	)
+
+	if opts != nil {
+		ttl, perm = opts.TTL, opts.Perm
+	}
+	if pkr, ok := parent.(*namedKeyring); ok && ttl == 0 {
+		pkr.mu.Lock()
+		ttl = pkr.ttl
+		pkr.mu.Unlock()
+	}
+
+	// Resolve special ids such as the session keyring once, so that every
+	// step refers to the same keyring even if the goroutine changes thread.
+	parentId, err := resolveKeyId(keyId(parent.Id()))
+	if err != nil {
+		return nil, err
+	}
+
+	kr, err := stageKeyring(parentId, name, ttl, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &namedKeyring{
+		keyring:    kr,
+		parent:     keyId(parent.Id()),
+		parentPath: pathOf(parent),
+		name:       name,
+		ttl:        ttl,
+	}, nil
+}
+
+// Seconds after which a staging keyring left behind by a process that died
+// while creating a keyring expires.
+const stageTimeout = 60
+
+var stageSeq uint32
+
+// Create a keyring in a uniquely named staging keyring linked to a parent,
+// set its ttl and permissions and then move it into the parent, failing with
+// EEXIST if the parent already has a keyring with that name. All of this
+// happens on the calling thread, so the caller's credentials and its
+// possession of the parent apply throughout.
+func stageKeyring(parent keyId, name string, ttl uint, perm KeyPerm) (*keyring, error) {
+	stageName := "_keyctl_stage." + strconv.Itoa(syscall.Getpid()) + "." +
+		strconv.FormatUint(uint64(atomic.AddUint32(&stageSeq, 1)), 10)
+	stage, err := createKeyring(parent, stageName)
+	if err != nil {
+		return nil, err
+	}
+	defer keyctl_Unlink(stage.id, parent)
+
+	if err = keyctl_SetTimeout(stage.id, stageTimeout); err != nil {
+		return nil, err
+	}
+
+	kr, err := createKeyring(stage.id, name)
+	if err != nil {
+		return nil, err
+	}
+	if ttl > 0 {
+		if err = keyctl_SetTimeout(kr.id, ttl); err != nil {
+			return nil, err
+		}
+	}
+	if perm != 0 {
+		if err = keyctl_SetPerm(kr.id, uint32(perm)); err != nil {
+			return nil, err
+		}
+	}
+
+	err = keyctl_Move(kr.id, stage.id, parent, keyctlMoveExcl)
+	if errors.Is(err, syscall.EOPNOTSUPP) {
+		var id keyId
+		if id, err = findKeyring(parent, name); err == nil && id != 0 {
+			err = newKeyctlError(keyctlLink, parent, syscall.EEXIST)
+		} else if err == nil {
+			err = keyctl_Link(kr.id, parent)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// Return the id of the keyring with the given name linked directly to a
+// parent keyring, or 0 if there is none.
+func findKeyring(parent keyId, name string) (keyId, error) {
+	ids, err := listKeys(parent)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		// Skip anything the caller isn't permitted to view.
+		desc, err := describeKeyId(id)
+		if err != nil {
+			continue
+		}
+		fields := bytes.SplitN(desc, []byte{';'}, 5)
+		if len(fields) == 5 && string(fields[0]) == "keyring" && string(fields[4]) == name {
+			return id, nil
+		}
+	}
+	return 0, nil
+}
+
 // Set the time to live in seconds for an entire keyring and all of its keys.
 // Only named keyrings can have their time-to-live set, the in-built keyrings
 // cannot (Session, UserSession, etc).
 func SetKeyringTTL(kr NamedKeyring, nsecs uint) error {
-	err := keyctl_SetTimeout(keyId(kr.Id()), nsecs)
+	nkr := kr.(*namedKeyring)
+
+	nkr.mu.Lock()
+	defer nkr.mu.Unlock()
+
+	err := keyctl_SetTimeout(nkr.id, nsecs)
 	if err == nil {
-		kr.(*namedKeyring).ttl = nsecs
+		nkr.ttl = nsecs
 	}
 	return err
 }
 
+// Set the time to live for an entire keyring and all of its keys as a
+// duration, rounded up to whole seconds. A zero or negative duration removes
+// the keyring's expiry. See SetKeyringTTL().
+func SetKeyringExpiry(kr NamedKeyring, d time.Duration) error {
+	return SetKeyringTTL(kr, expirySeconds(d))
+}
+
 // Link an object to a keyring
 func Link(parent Keyring, child Id) error {
 	return keyctl_Link(keyId(child.Id()), keyId(parent.Id()))
@@ -193,7 +615,61 @@ func Unlink(parent Keyring, child Id) error {
 	return keyctl_Unlink(keyId(child.Id()), keyId(parent.Id()))
 }
 
+// Atomically move a key or keyring from one keyring to another, so that there
+// is no window in which it is linked to both or neither. If exclusive is true
+// and the destination already contains a key of the same type and name, the
+// move fails with EEXIST rather than displacing it.
+//
+// When a *Key is moved, its keyring is updated so that a later Unlink()
+// targets the destination, and the same *Key is returned. Named keyrings have
+// their parent and Path() updated likewise; for these and any other objects
+// nil is returned.
+func Move(key Id, from, to Keyring, exclusive bool) (*Key, error) {
+	var flags uint
+
+	if exclusive {
+		flags |= keyctlMoveExcl
+	}
+	err := keyctl_Move(keyId(key.Id()), keyId(from.Id()), keyId(to.Id()), flags)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := key.(type) {
+	case *Key:
+		t.mu.Lock()
+		t.ring = keyId(to.Id())
+		t.mu.Unlock()
+		return t, nil
+	case *namedKeyring:
+		path := pathOf(to)
+		t.mu.Lock()
+		t.parent, t.parentPath = keyId(to.Id()), path
+		t.mu.Unlock()
+	}
+	return nil, nil
+}
+
+// Revoke a key or keyring. Once revoked, any further attempt to use the object
+// fails with ErrKeyRevoked, even if it remains linked to other keyrings.
+func Revoke(k Id) error {
+	return keyctl_Revoke(keyId(k.Id()))
+}
+
+// Invalidate a key or keyring. The object is immediately unlinked from every
+// keyring it is linked to and destroyed, regardless of how many links to it
+// exist.
+func Invalidate(k Id) error {
+	return keyctl_Invalidate(keyId(k.Id()))
+}
+
 // Unlink a named keyring from its parent.
 func UnlinkKeyring(kr NamedKeyring) error {
-	return keyctl_Unlink(keyId(kr.Id()), kr.(*namedKeyring).parent)
+	nkr := kr.(*namedKeyring)
+
+	nkr.mu.Lock()
+	parent := nkr.parent
+	nkr.mu.Unlock()
+
+	return keyctl_Unlink(nkr.id, parent)
 }
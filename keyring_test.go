@@ -1,7 +1,15 @@
 package keyctl
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestAdd100BytesToSessionKeyring(t *testing.T) {
@@ -112,6 +120,46 @@ func TestCreateKeyring(t *testing.T) {
 	}
 }
 
+func TestKeyringExpiry(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "expiryring", t)
+	defer UnlinkKeyring(ring)
+
+	if d, err := ring.Remaining(); err != nil || d != 0 {
+		t.Fatalf("expected no expiry, got %v (%v)", d, err)
+	}
+
+	if err := SetKeyringExpiry(ring, 50*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := ring.Remaining(); err != nil || d <= 45*time.Second || d > 50*time.Second {
+		t.Fatalf("unexpected time remaining %v (%v)", d, err)
+	}
+	if exp, err := ring.ExpiresAt(); err != nil || exp.IsZero() {
+		t.Fatalf("unexpected expiry time %v (%v)", exp, err)
+	}
+
+	ring.SetDefaultExpiry(1500 * time.Millisecond)
+	key, err := ring.Add("default-expiry", []byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := key.Remaining(); err != nil || d <= 0 || d > 2*time.Second {
+		t.Fatalf("unexpected key time remaining %v (%v)", d, err)
+	}
+
+	if err = SetKeyringExpiry(ring, 0); err != nil {
+		t.Fatal(err)
+	}
+	if exp, err := ring.ExpiresAt(); err != nil || !exp.IsZero() {
+		t.Fatalf("expected expiry to be removed, got %v (%v)", exp, err)
+	}
+
+	session := helperSessionKeyring(t)
+	if _, err = session.Remaining(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestCreateNestedKeyring(t *testing.T) {
 	ring := helperTestCreateKeyring(nil, "", t)
 
@@ -170,3 +218,430 @@ func TestUnlinkKeyring(t *testing.T) {
 
 	t.Logf("unlinked keyring %v [%s]", nring.Id(), nring.Name())
 }
+
+func TestClearKeyring(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "clearring", t)
+	defer UnlinkKeyring(ring)
+
+	for _, name := range []string{"clear1", "clear2"} {
+		if _, err := ring.Add(name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ring.Clear(); err != nil {
+		t.Fatal(err)
+	}
+
+	refs, err := ListKeyring(ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 0 {
+		t.Fatalf("expected empty keyring after Clear(), found %d entries", len(refs))
+	}
+	for _, name := range []string{"clear1", "clear2"} {
+		if _, err = ring.Search(name); err == nil {
+			t.Fatalf("search for %q expected to fail after Clear()", name)
+		}
+	}
+}
+
+func TestInvalidateKey(t *testing.T) {
+	session, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring := helperTestCreateKeyring(session, "invalidatering", t)
+	defer UnlinkKeyring(ring)
+
+	key, err := ring.Add("invalidate-test", []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Link the key elsewhere to ensure invalidation removes it everywhere.
+	if err = Link(session, key); err != nil {
+		t.Fatal(err)
+	}
+	if err = key.Invalidate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ring.Search("invalidate-test"); err == nil {
+		t.Fatal("search expected to fail after Invalidate()")
+	}
+	if _, err = session.Search("invalidate-test"); err == nil {
+		t.Fatal("search of second keyring expected to fail after Invalidate()")
+	}
+}
+
+func helperJoinSession(name string) (session Info, err error) {
+	done := make(chan struct{})
+	go func() {
+		// Deliberately left locked to the thread so that it is discarded
+		// when the goroutine exits.
+		defer close(done)
+		var ring Keyring
+		if name == "" {
+			if _, err = JoinAnonymousSessionKeyring(); err != nil {
+				return
+			}
+		} else if _, err = JoinSessionKeyring(name); err != nil {
+			return
+		}
+		if ring, err = SessionKeyring(); err == nil {
+			session, err = ring.Info()
+		}
+	}()
+	<-done
+	return
+}
+
+func TestJoinSessionKeyring(t *testing.T) {
+	session, err := helperJoinSession("keyctl-test-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Name != "keyctl-test-session" {
+		t.Fatalf("joined session keyring unexpectedly named %q", session.Name)
+	}
+
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ring.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name == session.Name {
+		t.Fatalf("joined session keyring %q leaked to other goroutines", session.Name)
+	}
+}
+
+func TestJoinSessionKeyringEmptyName(t *testing.T) {
+	if _, err := JoinSessionKeyring(""); err != ErrSessionNameRequired {
+		t.Fatalf("expected ErrSessionNameRequired, got %v", err)
+	}
+}
+
+func TestJoinAnonymousSessionKeyring(t *testing.T) {
+	session, err := helperJoinSession("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Name != "_ses" {
+		t.Fatalf("anonymous session keyring unexpectedly named %q", session.Name)
+	}
+}
+
+func TestSessionToParentDenied(t *testing.T) {
+	status, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", os.Getppid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Never replace the session of a single threaded parent such as the
+	// developer's own shell.
+	if bytes.Contains(status, []byte("\nThreads:\t1\n")) {
+		t.Skip("parent process is single threaded")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err = JoinAnonymousSessionKeyring(); err == nil {
+			err = SessionToParent()
+		}
+	}()
+	<-done
+
+	if !errors.Is(err, ErrSessionToParentDenied) {
+		t.Fatalf("expected ErrSessionToParentDenied for multi-threaded parent, got %v", err)
+	}
+}
+
+func TestPersistentKeyring(t *testing.T) {
+	session, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ring, err := PersistentKeyring(-1, session)
+	if errors.Is(err, ErrPersistentKeyringsUnsupported) {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer Unlink(session, ring)
+
+	info, err := ring.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := fmt.Sprintf("_persistent.%d", os.Geteuid()); info.Name != expected {
+		t.Fatalf("expected persistent keyring named %q, got %q", expected, info.Name)
+	}
+}
+
+func TestPersistentKeyringNilLink(t *testing.T) {
+	if _, err := PersistentKeyring(-1, nil); !errors.Is(err, syscall.EINVAL) {
+		t.Fatalf("expected EINVAL, got %v", err)
+	}
+}
+
+func TestRestrictAllLinks(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "restrictring", t)
+	defer UnlinkKeyring(ring)
+
+	if _, err := ring.Add("restrict-before", []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestrictAllLinks(ring); err != nil {
+		helperSkipUnsupported(t, err)
+		t.Fatal(err)
+	}
+	if _, err := ring.Add("restrict-after", []byte{1}); err == nil {
+		t.Fatal("adding key to restricted keyring expected to fail")
+	}
+
+	info, err := ring.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Restricted || info.Restriction != "" {
+		t.Fatalf("unexpected restriction state %v %q", info.Restricted, info.Restriction)
+	}
+}
+
+func TestRestrictBuiltinTrusted(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "restrictring", t)
+	defer UnlinkKeyring(ring)
+
+	if err := RestrictBuiltinTrusted(ring); err != nil {
+		helperSkipUnsupported(t, err)
+		t.Fatal(err)
+	}
+	if _, err := ring.Add("restrict-user", []byte{1}); err == nil {
+		t.Fatal("adding user key to restricted keyring expected to fail")
+	}
+
+	info, err := ring.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Restricted || info.Restriction != "asymmetric builtin_trusted" {
+		t.Fatalf("unexpected restriction state %v %q", info.Restricted, info.Restriction)
+	}
+	if err = RestrictAllLinks(ring); err == nil {
+		t.Fatal("restricting an already restricted keyring expected to fail")
+	}
+}
+
+func TestMoveKey(t *testing.T) {
+	from := helperTestCreateKeyring(nil, "movefrom", t)
+	defer UnlinkKeyring(from)
+	to := helperTestCreateKeyring(nil, "moveto", t)
+	defer UnlinkKeyring(to)
+
+	key, err := from.Add("move-test", []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = to.Add("move-test", []byte{4, 5, 6}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = Move(key, from, to, true); err == nil {
+		t.Fatal("exclusive move over existing key expected to fail")
+	} else {
+		helperSkipUnsupported(t, err)
+	}
+
+	moved, err := Move(key, from, to, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != key || key.ring != keyId(to.Id()) {
+		t.Fatalf("moved key not updated to keyring %v", to.Id())
+	}
+	if _, err = from.Search("move-test"); err == nil {
+		t.Fatal("key still found in source keyring after move")
+	}
+
+	buf, err := key.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	helperCmp(t, buf, []byte{1, 2, 3})
+
+	if err = key.Unlink(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = to.Search("move-test"); err == nil {
+		t.Fatal("key still found in destination keyring after unlink")
+	}
+}
+
+func TestKeyringConcurrentAccess(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "concurrentring", t)
+	defer UnlinkKeyring(ring)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				var err error
+				switch (i + j) % 4 {
+				case 0:
+					ring.SetDefaultTimeout(uint(60 + j))
+				case 1:
+					_, err = ring.Add(fmt.Sprintf("key-%d-%d", i, j), []byte{byte(j)})
+				case 2:
+					err = SetKeyringTTL(ring, uint(120+j))
+				case 3:
+					_, err = ring.Info()
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestOpenOrCreateKeyring(t *testing.T) {
+	parent := helperTestCreateKeyring(nil, "openorcreate", t)
+	defer UnlinkKeyring(parent)
+
+	ring, err := OpenOrCreateKeyring(parent, "child", &KeyringOptions{TTL: 300, Perm: 0x3f3f0000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ring.Add("keep-me", []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := ring.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Perm != 0x3f3f0000 {
+		t.Errorf("expected permissions %v, got %v", KeyPerm(0x3f3f0000), info.Perm)
+	}
+	if err = info.LoadDetails(); err != nil {
+		t.Fatal(err)
+	}
+	if info.Expires.IsZero() {
+		t.Error("expected keyring to expire")
+	}
+	if refs, err := ListKeyring(parent); err != nil || len(refs) != 1 {
+		t.Errorf("expected only the new keyring in the parent, got %v (%v)", refs, err)
+	}
+
+	again, err := OpenOrCreateKeyring(parent, "child", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Id() != ring.Id() {
+		t.Fatalf("expected existing keyring %v, got %v", ring.Id(), again.Id())
+	}
+	if _, err = again.Search("keep-me"); err != nil {
+		t.Fatalf("key lost from reopened keyring: %v", err)
+	}
+
+	// Only direct children of the parent are considered.
+	nested, err := OpenOrCreateKeyring(ring, "grandchild", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct, err := OpenOrCreateKeyring(parent, "grandchild", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if direct.Id() == nested.Id() {
+		t.Fatal("expected a new keyring rather than one linked below the parent")
+	}
+}
+
+func TestOpenOrCreateKeyringHidden(t *testing.T) {
+	parent := helperTestCreateKeyring(nil, "openorcreatehidden", t)
+	defer UnlinkKeyring(parent)
+
+	hidden := helperTestCreateKeyring(parent, "hidden", t)
+	if err := SetPerm(hidden, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenOrCreateKeyring(parent, "hidden", nil); !errors.Is(err, syscall.EEXIST) {
+		t.Fatalf("expected EEXIST, got %v", err)
+	}
+}
+
+func TestOpenOrCreateKeyringJoinedSession(t *testing.T) {
+	done := make(chan error)
+	go func() {
+		// Left locked to the thread, see helperJoinSession().
+		_, err := JoinAnonymousSessionKeyring()
+		if err == nil {
+			err = func() error {
+				session, err := SessionKeyring()
+				if err != nil {
+					return err
+				}
+				ring, err := OpenOrCreateKeyring(session, "joined", &KeyringOptions{Perm: 0x3f3f0000})
+				if err != nil {
+					return err
+				}
+				again, err := OpenOrCreateKeyring(session, "joined", nil)
+				if err == nil && again.Id() != ring.Id() {
+					err = fmt.Errorf("expected existing keyring %v, got %v", ring.Id(), again.Id())
+				}
+				return err
+			}()
+		}
+		done <- err
+	}()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenOrCreateKeyringConcurrent(t *testing.T) {
+	parent := helperTestCreateKeyring(nil, "openorcreaterace", t)
+	defer UnlinkKeyring(parent)
+
+	ids := make(chan int32, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(ids); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ring, err := OpenOrCreateKeyring(parent, "shared", nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids <- ring.Id()
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	first := int32(0)
+	for id := range ids {
+		if first == 0 {
+			first = id
+		} else if id != first {
+			t.Fatalf("expected all callers to share keyring %v, got %v", first, id)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package keyctl
+
+import (
+	"errors"
+	"strings"
+)
+
+// Error returned when a logon key name lacks the "service:" prefix required
+// by the kernel.
+var ErrInvalidLogonName = errors.New("logon key name must begin with a \"service:\" prefix")
+
+// Largest payload, in bytes, the kernel accepts for "user" and "logon" keys.
+const MaxUserKeySize = 32767
+
+// Largest payload, in bytes, the kernel accepts for "big_key" keys, and for
+// any key passed to add_key(2).
+const MaxBigKeySize = 1<<20 - 1
+
+// Kernel key types which can be added to a keyring with AddWithType() or
+// searched for with SearchWithType(). Not every kernel has every type
+// available; adding a key of an unsupported type fails with ENODEV.
+const (
+	// General purpose keys whose payload can be read back by userspace.
+	TypeUser = "user"
+	// Like "user" keys but the payload can never be read back by userspace.
+	TypeLogon = "logon"
+	// Large payload keys which may be stored encrypted outside of kernel
+	// memory.
+	TypeBigKey = "big_key"
+	// Keys encrypted and decrypted by the kernel using a master key.
+	TypeEncrypted = "encrypted"
+	// Keys sealed by a trust source such as a TPM.
+	TypeTrusted = "trusted"
+	// Public or private keys used for in-kernel cryptographic operations.
+	TypeAsymmetric = "asymmetric"
+	// Cached DNS lookups.
+	TypeDNSResolver = "dns_resolver"
+)
+
+// Add a new "encrypted" key to a keyring. The command is passed verbatim to
+// the kernel and takes the form "new [format] key-type:master-key-name
+// keylen" or "load [format] key-type:master-key-name keylen hex_blob".
+func AddEncrypted(ring Keyring, name, command string) (*Key, error) {
+	return ring.AddWithType(TypeEncrypted, name, []byte(command))
+}
+
+// Add a new "trusted" key to a keyring. The command is passed verbatim to
+// the kernel and takes the form "new keylen [options]" or "load hex_blob
+// [options]".
+func AddTrusted(ring Keyring, name, command string) (*Key, error) {
+	return ring.AddWithType(TypeTrusted, name, []byte(command))
+}
+
+// Add a new "dns_resolver" key to a keyring, caching the result of a DNS
+// lookup.
+func AddDNSResolver(ring Keyring, name string, payload []byte) (*Key, error) {
+	return ring.AddWithType(TypeDNSResolver, name, payload)
+}
+
+// Add a new "logon" key to a keyring. The name must be prefixed with a
+// service name and colon (e.g. "ldap:admin"). Once added, the payload can be
+// used by the kernel but never read back; calling Get() on the returned key
+// fails with ErrKeyUnreadable.
+func AddLogon(ring Keyring, name string, payload []byte) (*Key, error) {
+	return ring.AddWithType(TypeLogon, name, payload)
+}
+
+// Search for a "logon" key by name. The name must be prefixed with a service
+// name and colon.
+func SearchLogon(ring Keyring, name string) (*Key, error) {
+	return ring.SearchWithType(TypeLogon, name)
+}
+
+// Add a new "big_key" key to a keyring. Such keys can hold payloads of up to
+// MaxBigKeySize bytes and may be stored encrypted in swappable memory rather
+// than in the kernel's own.
+func AddBigKey(ring Keyring, name string, payload []byte) (*Key, error) {
+	return ring.AddWithType(TypeBigKey, name, payload)
+}
+
+// Add a new "asymmetric" key to a keyring from a DER encoded X.509
+// certificate or PKCS#8 private key. If name is empty the kernel generates a
+// description from the key itself, which is then used as the key's Name.
+func AddAsymmetric(ring Keyring, name string, payload []byte) (*Key, error) {
+	key, err := ring.AddWithType(TypeAsymmetric, name, payload)
+	if err == nil && name == "" {
+		var info Info
+		if info, err = key.Info(); err == nil {
+			key.Name = info.Name
+		}
+	}
+	return key, err
+}
+
+func validateName(keyType, name string) error {
+	if keyType == TypeLogon {
+		if i := strings.IndexByte(name, ':'); i < 1 {
+			return ErrInvalidLogonName
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package keyctl
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestAddWithType(t *testing.T) {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blk := helperRandBlock(64)
+	key, err := ring.AddWithType(TypeUser, "typed-user", blk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Unlink()
+
+	if key.Type() != TypeUser {
+		t.Fatalf("unexpected key type %q", key.Type())
+	}
+
+	found, err := ring.SearchWithType(TypeUser, "typed-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Id() != key.Id() || found.Type() != TypeUser {
+		t.Fatalf("search returned key %v (%s), expected %v", found.Id(), found.Type(), key.Id())
+	}
+
+	buf, err := found.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	helperCmp(t, buf, blk)
+}
+
+func TestAddWithUnknownType(t *testing.T) {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ring.AddWithType("no_such_key_type", "typed-unknown", []byte{1})
+	if !errors.Is(err, syscall.ENODEV) {
+		t.Fatalf("expected ENODEV adding unknown key type, got %v", err)
+	}
+}
+
+func TestReferenceKeyType(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "typedring", t)
+	defer UnlinkKeyring(ring)
+
+	if _, err := ring.AddWithType(TypeUser, "typed-ref", []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	refs, err := ListKeyring(ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 {
+		t.Fatalf("expected 1 reference, found %d", len(refs))
+	}
+
+	id, err := refs[0].Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, ok := id.(*Key)
+	if !ok {
+		t.Fatalf("expected *Key, got %T", id)
+	}
+	if key.Type() != TypeUser || key.Name != "typed-ref" {
+		t.Fatalf("unexpected key %q of type %q", key.Name, key.Type())
+	}
+}
+
+func TestLogonKey(t *testing.T) {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = AddLogon(ring, "noprefix", []byte("secret")); err != ErrInvalidLogonName {
+		t.Fatalf("expected ErrInvalidLogonName, got %v", err)
+	}
+	if _, err = AddLogon(ring, ":noservice", []byte("secret")); err != ErrInvalidLogonName {
+		t.Fatalf("expected ErrInvalidLogonName, got %v", err)
+	}
+
+	key, err := AddLogon(ring, "keyctl:logon-test", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Unlink()
+
+	if _, err = key.Get(); !errors.Is(err, ErrKeyUnreadable) {
+		t.Fatalf("expected ErrKeyUnreadable, got %v", err)
+	}
+
+	found, err := SearchLogon(ring, "keyctl:logon-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Id() != key.Id() {
+		t.Fatalf("search returned key %v, expected %v", found.Id(), key.Id())
+	}
+
+	// Bypass the type check to ensure the kernel's refusal is also mapped.
+	raw := &Key{Name: key.Name, id: key.id, ring: key.ring}
+	if _, err = raw.Get(); !errors.Is(err, ErrKeyUnreadable) {
+		t.Fatalf("expected ErrKeyUnreadable from kernel, got %v", err)
+	}
+}
+
+func TestAddBigKeyMaxSize(t *testing.T) {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// add_key(2) checks the payload size before looking up the key type.
+	if _, err = AddBigKey(ring, "too-big", make([]byte, MaxBigKeySize+1)); !errors.Is(err, syscall.EINVAL) {
+		t.Fatalf("expected EINVAL adding %d bytes, got %v", MaxBigKeySize+1, err)
+	}
+
+	key, err := AddBigKey(ring, "max-size", make([]byte, MaxBigKeySize))
+	if err != nil {
+		helperSkipUnsupported(t, err)
+		t.Fatal(err)
+	}
+	defer key.Unlink()
+}
@@ -0,0 +1,109 @@
+package keyctl
+
+import (
+	"errors"
+)
+
+// Error returned by PublicKeyOps.Verify() when a signature does not match.
+var ErrBadSignature = errors.New("keyctl signature verification failed")
+
+// PKeyOps is the set of operations supported by an asymmetric key.
+type PKeyOps uint32
+
+const (
+	PKeySupportsEncrypt PKeyOps = 1 << iota
+	PKeySupportsDecrypt
+	PKeySupportsSign
+	PKeySupportsVerify
+)
+
+// Information about an asymmetric key as returned by PublicKeyOps.Query().
+// All sizes other than KeySize are in bytes.
+type PKeyQuery struct {
+	Supported PKeyOps
+	// Size of the key in bits.
+	KeySize     int
+	MaxDataSize int
+	MaxSigSize  int
+	MaxEncSize  int
+	MaxDecSize  int
+}
+
+// PublicKeyOps performs cryptographic operations using an "asymmetric" key
+// held by the kernel, without the key material ever entering the process.
+//
+// Each operation takes an info string of space separated key=value pairs
+// which is interpreted by the kernel, for example "enc=pkcs1 hash=sha256".
+// "enc" selects the encoding (e.g. "pkcs1", "raw", "x962") and "hash" names
+// the hash algorithm a digest was produced with.
+type PublicKeyOps struct {
+	key *Key
+}
+
+// Returns a PublicKeyOps for the given key, which must be of type
+// "asymmetric", otherwise ErrUnsupportedKeyType is returned.
+func NewPublicKeyOps(key *Key) (*PublicKeyOps, error) {
+	if key.Type() != TypeAsymmetric {
+		return nil, ErrUnsupportedKeyType
+	}
+	return &PublicKeyOps{key: key}, nil
+}
+
+// Returns the key the operations are performed with.
+func (p *PublicKeyOps) Key() *Key {
+	return p.key
+}
+
+// Query the kernel for the operations and sizes supported by the key when
+// used with the given info string.
+func (p *PublicKeyOps) Query(info string) (PKeyQuery, error) {
+	q, err := keyctl_PKeyQuery(p.key.id, info)
+	if err != nil {
+		return PKeyQuery{}, err
+	}
+	return PKeyQuery{
+		Supported:   PKeyOps(q.supportedOps),
+		KeySize:     int(q.keySize),
+		MaxDataSize: int(q.maxDataSize),
+		MaxSigSize:  int(q.maxSigSize),
+		MaxEncSize:  int(q.maxEncSize),
+		MaxDecSize:  int(q.maxDecSize),
+	}, nil
+}
+
+// Encrypt data with the key.
+func (p *PublicKeyOps) Encrypt(info string, data []byte) ([]byte, error) {
+	return p.op(keyctlPKeyEncrypt, info, data, func(q PKeyQuery) int { return q.MaxEncSize })
+}
+
+// Decrypt data with the key, which must be a private key.
+func (p *PublicKeyOps) Decrypt(info string, data []byte) ([]byte, error) {
+	return p.op(keyctlPKeyDecrypt, info, data, func(q PKeyQuery) int { return q.MaxDecSize })
+}
+
+// Sign a digest with the key, which must be a private key. The info string
+// should name the hash used to produce the digest.
+func (p *PublicKeyOps) Sign(info string, digest []byte) ([]byte, error) {
+	return p.op(keyctlPKeySign, info, digest, func(q PKeyQuery) int { return q.MaxSigSize })
+}
+
+// Verify the signature of a digest with the key. The error returned matches
+// ErrBadSignature if the signature does not match.
+func (p *PublicKeyOps) Verify(info string, digest, sig []byte) error {
+	_, err := keyctl_PKeyOp(keyctlPKeyVerify, p.key.id, info, digest, sig)
+	return err
+}
+
+func (p *PublicKeyOps) op(cmd keyctlCommand, info string, in []byte, size func(PKeyQuery) int) ([]byte, error) {
+	q, err := p.Query(info)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, size(q))
+	n, err := keyctl_PKeyOp(cmd, p.key.id, info, in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out[:n], nil
+}
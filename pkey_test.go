@@ -0,0 +1,104 @@
+package keyctl
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func helperTestCertificate(t *testing.T) (*rsa.PrivateKey, []byte) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "keyctl test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, der
+}
+
+func helperAddAsymmetric(t *testing.T, payload []byte) *Key {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := AddAsymmetric(ring, "", payload)
+	if filterErrno(err, syscall.ENODEV, syscall.ENOPKG, syscall.EBADMSG, syscall.EOPNOTSUPP) == nil && err != nil {
+		t.Skipf("asymmetric keys not supported by this kernel: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestPublicKeyOps(t *testing.T) {
+	priv, der := helperTestCertificate(t)
+	key := helperAddAsymmetric(t, der)
+	defer key.Unlink()
+
+	t.Logf("added asymmetric key %v: %q", key.Id(), key.Name)
+	ops, err := NewPublicKeyOps(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := ops.Query("enc=pkcs1 hash=sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.KeySize != 2048 {
+		t.Fatalf("unexpected key size %d", q.KeySize)
+	}
+	if q.Supported&PKeySupportsVerify == 0 {
+		t.Fatalf("public key does not support verification (%#x)", q.Supported)
+	}
+	t.Logf("query: %+v", q)
+
+	if q.Supported&PKeySupportsEncrypt != 0 {
+		msg := []byte("kernel encrypted secret")
+		enc, err := ops.Encrypt("enc=pkcs1", msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, err := rsa.DecryptPKCS1v15(rand.Reader, priv, enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		helperCmp(t, dec, msg)
+	}
+
+	digest := sha256.Sum256([]byte("signed message"))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ops.Verify("enc=pkcs1 hash=sha256", digest[:], sig); err != nil {
+		t.Fatal(err)
+	}
+	other := sha256.Sum256([]byte("some other message"))
+	if err = ops.Verify("enc=pkcs1 hash=sha256", other[:], sig); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected ErrBadSignature verifying mismatched signature, got %v", err)
+	}
+}
+
+func TestPublicKeyOpsUnsupportedType(t *testing.T) {
+	if _, err := NewPublicKeyOps(&Key{typ: TypeUser}); err != ErrUnsupportedKeyType {
+		t.Fatalf("expected ErrUnsupportedKeyType, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package keyctl
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error returned by ExpiresAt() and Remaining() when a key cannot be found in
+// /proc/keys, either because it does not exist or the caller lacks
+// permission to view it.
+var ErrNotInProcKeys = errors.New("keyctl key not listed in /proc/keys")
+
+const procKeysPath = "/proc/keys"
+
+// KeyFlags represents the state flags of a key as reported by /proc/keys.
+type KeyFlags uint
+
+const (
+	// The key has been instantiated.
+	FlagInstantiated KeyFlags = 1 << iota
+	// The key has been revoked.
+	FlagRevoked
+	// The key type has been unregistered and the key is awaiting destruction.
+	FlagDead
+	// The key contributes to the owner's quota.
+	FlagQuota
+	// The key is under construction by a request-key helper.
+	FlagUnderConstruction
+	// The key was negatively instantiated.
+	FlagNegative
+	// The key has been invalidated.
+	FlagInvalidated
+)
+
+var keyFlagChars = []byte("IRDQUNi")
+
+// Returns the flags in the same form as /proc/keys, e.g. "I--Q---".
+func (f KeyFlags) String() string {
+	out := make([]byte, len(keyFlagChars))
+	for i, c := range keyFlagChars {
+		if f&(1<<uint(i)) == 0 {
+			out[i] = '-'
+		} else {
+			out[i] = c
+		}
+	}
+	return string(out)
+}
+
+type procKey struct {
+	serial  int32
+	flags   KeyFlags
+	usage   int
+	expires time.Time
+}
+
+// Find a key's entry in /proc/keys.
+func readProcKey(id keyId) (*procKey, error) {
+	f, err := os.Open(procKeysPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, ' '); i > 0 {
+			if serial, err := strconv.ParseUint(line[:i], 16, 32); err == nil && keyId(serial) == id {
+				return parseProcKey(line, now)
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrNotInProcKeys
+}
+
+// Parse a line of /proc/keys in the form:
+//
+//	<serial> <flags> <usage> <expiry> <perm> <uid> <gid> <type> <description>
+//
+// Expiry is reported relative to now in the largest whole unit of seconds,
+// minutes, hours, days or weeks, or as "perm" or "expd".
+func parseProcKey(line string, now time.Time) (*procKey, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 8 {
+		return nil, errors.New("malformed /proc/keys entry: " + line)
+	}
+
+	serial, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	pk := &procKey{serial: int32(serial)}
+
+	for i, c := range []byte(fields[1]) {
+		if i < len(keyFlagChars) && c == keyFlagChars[i] {
+			pk.flags |= 1 << uint(i)
+		}
+	}
+
+	if pk.usage, err = strconv.Atoi(fields[2]); err != nil {
+		return nil, err
+	}
+
+	switch exp := fields[3]; exp {
+	case "perm":
+	case "expd":
+		pk.expires = now
+	default:
+		if len(exp) < 2 {
+			return nil, errors.New("malformed /proc/keys expiry: " + exp)
+		}
+		n, err := strconv.ParseUint(exp[:len(exp)-1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		unit := time.Second
+		switch exp[len(exp)-1] {
+		case 's':
+		case 'm':
+			unit = time.Minute
+		case 'h':
+			unit = time.Hour
+		case 'd':
+			unit = 24 * time.Hour
+		case 'w':
+			unit = 7 * 24 * time.Hour
+		default:
+			return nil, errors.New("malformed /proc/keys expiry: " + exp)
+		}
+		pk.expires = now.Add(time.Duration(n) * unit)
+	}
+
+	return pk, nil
+}
@@ -0,0 +1,130 @@
+package keyctl
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseProcKey(t *testing.T) {
+	now := time.Now()
+
+	for _, c := range []struct {
+		line    string
+		serial  int32
+		flags   KeyFlags
+		usage   int
+		expires time.Time
+	}{
+		{"05fa48f2 I--Q---     1 perm 1f3f0000     0 65534 keyring   _uid_ses.0: 1", 0x05fa48f2, FlagInstantiated | FlagQuota, 1, time.Time{}},
+		{"1c0b2a3d I--Q---     2   59s 3f010000     0     0 user      expire-test: 128", 0x1c0b2a3d, FlagInstantiated | FlagQuota, 2, now.Add(59 * time.Second)},
+		{"1c0b2a3e IR-Q---     1    3h 3f010000     0     0 user      revoked: 1", 0x1c0b2a3e, FlagInstantiated | FlagRevoked | FlagQuota, 1, now.Add(3 * time.Hour)},
+		{"1c0b2a3f I--Q--i     1    2w 3f010000     0     0 user      invalid: 1", 0x1c0b2a3f, FlagInstantiated | FlagQuota | FlagInvalidated, 1, now.Add(14 * 24 * time.Hour)},
+		{"1c0b2a40 ---Q-N-     1  expd 3f010000     0     0 user      negative", 0x1c0b2a40, FlagQuota | FlagNegative, 1, now},
+	} {
+		pk, err := parseProcKey(c.line, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pk.serial != c.serial || pk.flags != c.flags || pk.usage != c.usage || !pk.expires.Equal(c.expires) {
+			t.Fatalf("unexpected parse of %q: %+v", c.line, pk)
+		}
+	}
+
+	if _, err := parseProcKey("05fa48f2 I--Q--- 1 5y 1f3f0000 0 0 user bad", now); err == nil {
+		t.Fatal("parsing invalid expiry expected to fail")
+	}
+}
+
+func TestKeyFlagsString(t *testing.T) {
+	if s := (FlagInstantiated | FlagQuota).String(); s != "I--Q---" {
+		t.Fatalf("unexpected flags string %q", s)
+	}
+}
+
+func TestInfoExpires(t *testing.T) {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ring.Add("info-expires", []byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Unlink()
+
+	info, err := key.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Serial != 0 {
+		t.Fatalf("expected details to be loaded only on request: %+v", info)
+	}
+	if err = info.LoadDetails(); err != nil {
+		t.Fatal(err)
+	}
+	if info.Serial != key.Id() || info.Flags&FlagInstantiated == 0 || info.Usage < 1 || !info.Expires.IsZero() {
+		t.Fatalf("unexpected info for permanent key: %+v", info)
+	}
+
+	if err = key.ExpireAfter(30); err != nil {
+		t.Fatal(err)
+	}
+	if info, err = key.Info(); err != nil {
+		t.Fatal(err)
+	}
+	if err = info.LoadDetails(); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := time.Until(info.Expires); remaining <= 25*time.Second || remaining > 30*time.Second {
+		t.Fatalf("unexpected expiry %v (%v remaining)", info.Expires, remaining)
+	}
+}
+
+func TestInfoSpecialKeyring(t *testing.T) {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ring.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = info.LoadDetails(); err != nil {
+		t.Fatal(err)
+	}
+	if info.Serial <= 0 || info.Flags&FlagInstantiated == 0 {
+		t.Fatalf("unexpected info for session keyring: %+v", info)
+	}
+}
+
+func TestExpiresAtUnlisted(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "unlisted", t)
+	key, err := ring.Add("unlisted", []byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = UnlinkKeyring(ring); err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the garbage collector to remove the key from /proc/keys.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err = key.ExpiresAt()
+		if err != nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !errors.Is(err, ErrNotInProcKeys) {
+		t.Fatalf("expected ErrNotInProcKeys, got %v", err)
+	}
+
+	auth, err := ParseSpec("@a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = auth.(*Key).Remaining(); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound outside a request-key helper, got %v", err)
+	}
+}
@@ -5,25 +5,32 @@ import (
 	"errors"
 	"os"
 	"strconv"
+	"sync"
+	"time"
 )
 
 var (
-	// Error returned if the Get() method is called on a Reference that doesn't
-	// represent a key or keychain.
+	// Error returned when a key is not of the type an operation requires.
 	ErrUnsupportedKeyType = errors.New("unsupported keyctl key type")
 	// Error returned if a reference is stale when Info() or Get() is called on
 	// it.
 	ErrInvalidReference = errors.New("invalid keyctl reference")
 )
 
+// Guards the cached info and security label of every Reference. References
+// are passed around by value, so they cannot carry a mutex of their own.
+var refMu sync.Mutex
+
 // Reference is a reference to an unloaded keyctl Key or Keychain. It can be
-// dereferenced by calling the Get() method.
+// dereferenced by calling the Get() method. A *Reference is safe for
+// concurrent use by multiple goroutines.
 type Reference struct {
 	// Id is the kernel key or keychain identifier referenced.
 	Id int32
 
-	info   *Info
-	parent keyId
+	info     *Info
+	security *string
+	parent   keyId
 }
 
 // Information about a keyctl reference as returned by ref.Info()
@@ -32,12 +39,38 @@ type Info struct {
 	Uid, Gid   int
 	Perm       KeyPerm
 
+	// Restricted is true if links to a keyring have been restricted by
+	// calling Restrict() on it, in which case Restriction holds the key type
+	// and restriction applied (empty if all links are blocked). The kernel
+	// does not report restrictions, so these are only set when the keyring
+	// was restricted through the same Keyring value.
+	Restricted  bool
+	Restriction string
+
+	// The LSM security label of the key (e.g. an SELinux context), empty if
+	// no LSM is labelling keys. Only set by LoadDetails().
+	Security string
+
+	// Details read from /proc/keys by LoadDetails(), left zero if the key is
+	// not listed there. Serial is the key's identifier, Usage the number of
+	// references held to it and Expires the time at which it expires (zero if
+	// it never does).
+	// The kernel reports the remaining lifetime in ever coarser units (minutes
+	// beyond 60 seconds, hours beyond 60 minutes and so on) so Expires is only
+	// accurate to within one such unit.
+	Serial  int32
+	Flags   KeyFlags
+	Usage   int
+	Expires time.Time
+
+	id    keyId
 	valid bool
 }
 
 func getInfo(id keyId) (i Info, err error) {
 	var desc []byte
 
+	i.id = id
 	if desc, err = describeKeyId(id); err != nil {
 		i.Name = err.Error()
 		return
@@ -69,6 +102,35 @@ func getInfo(id keyId) (i Info, err error) {
 	return
 }
 
+// Load the details of a key that are not returned by the kernel along with
+// the rest of its Info: the Security label and the Serial, Flags, Usage and
+// Expires fields. These are left unset by Info() because finding a key in
+// /proc/keys means reading the entry of every key the process can view.
+func (i *Info) LoadDetails() error {
+	if !i.valid {
+		return ErrInvalidReference
+	}
+
+	security, err := getSecurity(i.id)
+	if err != nil {
+		return err
+	}
+	serial, err := resolveKeyId(i.id)
+	if err != nil {
+		return err
+	}
+	pk, err := readProcKey(serial)
+	if err == ErrNotInProcKeys {
+		pk, err = &procKey{}, nil
+	} else if err != nil {
+		return err
+	}
+
+	i.Security = security
+	i.Serial, i.Flags, i.Usage, i.Expires = pk.serial, pk.flags, pk.usage, pk.expires
+	return nil
+}
+
 // Returns permissions in symbolic format.
 func (i Info) Permissions() string {
 	if i.Uid == os.Geteuid() {
@@ -84,13 +146,41 @@ func (i Info) Permissions() string {
 
 // Return Information about a keyctl reference.
 func (r *Reference) Info() (i Info, err error) {
-	if r.info == nil {
+	refMu.Lock()
+	info := r.info
+	refMu.Unlock()
+
+	if info == nil {
 		i, err = getInfo(keyId(r.Id))
+		refMu.Lock()
 		r.info = &i
+		refMu.Unlock()
 		return
 	}
 
-	return *r.info, err
+	return *info, err
+}
+
+// Return the LSM security label of a keyctl reference. The label is loaded
+// on first use and cached thereafter; a failed read is not cached, so the
+// next call tries again.
+func (r *Reference) Security() (string, error) {
+	refMu.Lock()
+	security := r.security
+	refMu.Unlock()
+
+	if security == nil {
+		label, err := getSecurity(keyId(r.Id))
+		if err != nil {
+			return "", err
+		}
+		refMu.Lock()
+		r.security = &label
+		refMu.Unlock()
+		return label, nil
+	}
+
+	return *security, nil
 }
 
 // Returns true if the Info fetched by ref.Info() is valid.
@@ -101,41 +191,41 @@ func (i Info) Valid() bool {
 // Returns true if the keyctl reference is valid. Refererences can become
 // invalid if they have expired since the reference was created.
 func (r *Reference) Valid() bool {
-	if r.info == nil {
-		r.Info()
-	}
-	return r.info.valid
+	i, _ := r.Info()
+	return i.valid
 }
 
-// Loads the referenced keyctl object, which must either be a key or a
-// keyring otherwise ErrUnsupportedKeyType will be returned.
+// Loads the referenced keyctl object. Keyrings are returned as a Keyring (a
+// NamedKeyring if they have a name) and keys of any other type as a *Key.
 func (r *Reference) Get() (Id, error) {
-	if r.info == nil {
-		_, err := r.Info()
-		if err != nil {
-			return nil, err
-		}
+	info, err := r.Info()
+	if err != nil {
+		return nil, err
 	}
 
-	if !r.info.valid {
+	if !info.valid {
 		return nil, ErrInvalidReference
 	}
 
-	switch r.info.Type {
-	case "key", "big_key":
-		return &Key{Name: r.info.Name, id: keyId(r.Id), ring: r.parent}, nil
+	switch info.Type {
+	case "key":
+		return &Key{Name: info.Name, id: keyId(r.Id), ring: r.parent, typ: TypeUser}, nil
 	case "keyring":
 		ring := &keyring{id: keyId(r.Id)}
-		if r.Id > 0 && r.info.Name != "" {
-			return &namedKeyring{
+		if r.Id > 0 && info.Name != "" {
+			nkr := &namedKeyring{
 				keyring: ring,
 				parent:  r.parent,
-				name:    r.info.Name,
-			}, nil
+				name:    info.Name,
+			}
+			if r.parent != 0 {
+				nkr.parentPath = idSpec(r.parent)
+			}
+			return nkr, nil
 		}
 		return ring, nil
 	default:
-		return nil, ErrUnsupportedKeyType
+		return &Key{Name: info.Name, id: keyId(r.Id), ring: r.parent, typ: info.Type}, nil
 	}
 }
 
@@ -1,6 +1,8 @@
 package keyctl
 
 import (
+	"errors"
+	"sync"
 	"syscall"
 	"testing"
 )
@@ -8,8 +10,8 @@ import (
 func mustInfo(r Reference) Info {
 	info, err := r.Info()
 	if err != nil {
-		if msg := err.Error(); msg == "key has expired" {
-			return Info{Name: msg}
+		if errors.Is(err, ErrKeyExpired) {
+			return Info{Name: err.Error()}
 		}
 	}
 	return info
@@ -37,7 +39,8 @@ func helperTestKeyRefs(ring Keyring, t *testing.T) []Reference {
 }
 
 func filterErrno(e error, ignore ...syscall.Errno) error {
-	if en, ok := e.(syscall.Errno); ok {
+	var en syscall.Errno
+	if errors.As(e, &en) {
 		for _, enok := range ignore {
 			if enok == en {
 				return nil
@@ -70,6 +73,9 @@ func helperRecurseKeyringRefs(kr Keyring, t *testing.T) {
 		case *Key:
 			t.Logf("key %v: %q, keyring %v", k.id, k.Name, k.ring)
 			data, err := k.Get()
+			if errors.Is(err, ErrKeyUnreadable) {
+				err = nil
+			}
 			if filterErrno(err, syscall.EPERM, syscall.EACCES) != nil {
 				t.Fatalf("%v %T(%d)", err, err, err)
 			}
@@ -83,3 +89,110 @@ func helperRecurseKeyringRefs(kr Keyring, t *testing.T) {
 func TestSessionKeyringRefs(t *testing.T) {
 	helperRecurseKeyringRefs(nil, t)
 }
+
+func TestReferenceSecurity(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "securityring", t)
+	defer UnlinkKeyring(ring)
+
+	if _, err := ring.Add("security-test", []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := ListKeyring(ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 {
+		t.Fatalf("expected 1 reference, found %d", len(refs))
+	}
+
+	label, err := refs[0].Security()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := refs[0].Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = info.LoadDetails(); err != nil {
+		t.Fatal(err)
+	}
+	if info.Security != label {
+		t.Fatalf("Info().Security %q does not match Security() %q", info.Security, label)
+	}
+	t.Logf("key %v security label %q", refs[0].Id, label)
+}
+
+func TestReferenceSecurityFailure(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "securityfail", t)
+	defer UnlinkKeyring(ring)
+
+	key, err := ring.Add("security-revoked", []byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs, err := ListKeyring(ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = refs[0].Info(); err != nil {
+		t.Fatal(err)
+	}
+	if err = key.Revoke(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cached info must not stand in for a label that cannot be read.
+	if label, err := refs[0].Security(); !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("expected ErrKeyRevoked, got %q, %v", label, err)
+	}
+}
+
+func TestReferenceConcurrentAccess(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "concurrentrefs", t)
+	defer UnlinkKeyring(ring)
+
+	if _, err := ring.Add("refkey", []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := ListKeyring(ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref := &refs[0]
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !ref.Valid() {
+				t.Error("expected reference to be valid")
+			}
+			if _, err := ref.Security(); err != nil {
+				t.Error(err)
+			}
+			if _, err := ref.Get(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestReferenceGetArbitraryType(t *testing.T) {
+	// Few key types beyond those built in can be relied on to exist, so fake
+	// the kernel's description of one.
+	r := Reference{Id: 1234, parent: 5678, info: &Info{Type: "rxrpc", Name: "afs@example.com", valid: true}}
+
+	obj, err := r.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, ok := obj.(*Key)
+	if !ok {
+		t.Fatalf("expected *Key, got %T", obj)
+	}
+	if key.Type() != "rxrpc" || key.Name != "afs@example.com" || key.id != 1234 || key.ring != 5678 {
+		t.Fatalf("unexpected key %+v", key)
+	}
+}
@@ -0,0 +1,90 @@
+package keyctl
+
+import (
+	"strconv"
+)
+
+// RequestKeyDest identifies the keyring that keys constructed by
+// request_key(2) are linked to when no destination keyring is given.
+type RequestKeyDest int
+
+const (
+	// Leave the current setting unchanged.
+	RequestKeyDestNoChange RequestKeyDest = iota - 1
+	// Use the first available of the thread, process, session, user-session
+	// and user keyrings.
+	RequestKeyDestDefault
+	RequestKeyDestThread
+	RequestKeyDestProcess
+	RequestKeyDestSession
+	RequestKeyDestUser
+	RequestKeyDestUserSession
+	RequestKeyDestGroup
+	// Use the requestor's keyring, set when the request is made on behalf of
+	// another process by a request-key helper.
+	RequestKeyDestRequestor
+)
+
+func (d RequestKeyDest) String() string {
+	switch d {
+	case RequestKeyDestNoChange:
+		return "no-change"
+	case RequestKeyDestDefault:
+		return "default"
+	case RequestKeyDestThread:
+		return "thread"
+	case RequestKeyDestProcess:
+		return "process"
+	case RequestKeyDestSession:
+		return "session"
+	case RequestKeyDestUser:
+		return "user"
+	case RequestKeyDestUserSession:
+		return "user-session"
+	case RequestKeyDestGroup:
+		return "group"
+	case RequestKeyDestRequestor:
+		return "requestor"
+	}
+	return "RequestKeyDest(" + strconv.Itoa(int(d)) + ")"
+}
+
+// Request a key of the given type and description. The process's keyrings are
+// searched first and, if no matching key is found and callout is not nil, the
+// kernel invokes /sbin/request-key to construct the key, passing it the
+// callout info. If dest is not nil the key is linked to it. Otherwise a newly
+// constructed key is linked to the default request-key keyring (see
+// SetDefaultRequestKeyDest()) and an existing key is left where it is. The
+// kernel does not report which keyring that is, so the Unlink() method of a
+// key requested without a destination always fails; use the Unlink()
+// function with the keyring instead.
+//
+// The error returned matches ErrKeyNotFound if no key could be found or
+// constructed, which also covers keys negated by the constructing program.
+// It matches ErrKeyExpired or ErrKeyRevoked if a matching key has expired or
+// been revoked and ErrKeyRejected if construction of the key was rejected.
+func RequestKey(keyType, description string, callout []byte, dest Keyring) (*Key, error) {
+	ring := ringId(dest)
+	r, err := request_key(keyType, description, callout, int32(ring))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{Name: description, id: keyId(r), ring: ring, typ: keyType}, nil
+}
+
+// Set the keyring that keys constructed by RequestKey() are linked to when no
+// destination keyring is given, returning the previous setting so that it can
+// be restored later. As with session keyrings, the kernel tracks this setting
+// per thread, so callers should lock the calling goroutine to its thread with
+// runtime.LockOSThread() for as long as the setting is relied upon.
+func SetDefaultRequestKeyDest(dest RequestKeyDest) (RequestKeyDest, error) {
+	prev, err := keyctl_SetReqKeyKeyring(int(dest))
+	return RequestKeyDest(prev), err
+}
+
+// Return the keyring that keys constructed by RequestKey() are currently
+// linked to when no destination keyring is given.
+func DefaultRequestKeyDest() (RequestKeyDest, error) {
+	return SetDefaultRequestKeyDest(RequestKeyDestNoChange)
+}
@@ -0,0 +1,85 @@
+package keyctl
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestRequestExistingKey(t *testing.T) {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ring.Add("request-test", []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Unlink()
+
+	found, err := RequestKey(TypeUser, "request-test", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Id() != key.Id() {
+		t.Fatalf("request returned key %v, expected %v", found.Id(), key.Id())
+	}
+
+	buf, err := found.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	helperCmp(t, buf, []byte{1, 2, 3})
+}
+
+func TestRequestMissingKey(t *testing.T) {
+	_, err := RequestKey(TypeUser, "abigbunchofnonsense", nil, nil)
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestRequestRevokedKey(t *testing.T) {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ring.Add("request-revoked", []byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Unlink()
+
+	if err = key.Revoke(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = RequestKey(TypeUser, "request-revoked", nil, nil); !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("expected ErrKeyRevoked, got %v", err)
+	}
+}
+
+func TestSetDefaultRequestKeyDest(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	orig, err := DefaultRequestKeyDest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("default request-key destination is %v", orig)
+
+	prev, err := SetDefaultRequestKeyDest(RequestKeyDestSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer SetDefaultRequestKeyDest(prev)
+
+	if prev != orig {
+		t.Fatalf("previous destination %v does not match original %v", prev, orig)
+	}
+	if cur, err := DefaultRequestKeyDest(); err != nil {
+		t.Fatal(err)
+	} else if cur != RequestKeyDestSession {
+		t.Fatalf("expected destination %v, got %v", RequestKeyDestSession, cur)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2015 Jesse Sipprell. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Provides a toolkit for writing request-key helper programs in Go. When a
+// process calls request_key(2) for a key that does not exist, the kernel
+// invokes /sbin/request-key (or a program configured in request-key.conf) to
+// construct it. Such a program assumes authority over the key under
+// construction, and then either instantiates it with a payload or negates
+// (rejects) it:
+//
+//	func main() {
+//		err := requestkey.Serve(os.Args, func(r *requestkey.Request) requestkey.Result {
+//			if r.Type != keyctl.TypeUser {
+//				return requestkey.Reject(60, syscall.EKEYREJECTED)
+//			}
+//			return requestkey.Payload([]byte("secret for " + r.Description))
+//		})
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+package requestkey
+
+import (
+	"errors"
+	"strconv"
+	"syscall"
+
+	"github.com/jsipprell/keyctl"
+)
+
+// Error returned when the arguments passed to a request-key helper are not
+// in the form used by the kernel.
+var ErrInvalidArgs = errors.New("invalid request-key arguments")
+
+// The key operations used to serve a request, replaced by tests.
+var ops = struct {
+	assumeAuthority func(key int32) error
+	info            func(key int32) (keyctl.Info, error)
+	calloutInfo     func() ([]byte, error)
+	instantiate     func(key int32, payload []byte, dest keyctl.Keyring) error
+	instantiateIov  func(key int32, payload [][]byte, dest keyctl.Keyring) error
+	negate          func(key int32, nsecs uint, dest keyctl.Keyring) error
+	reject          func(key int32, nsecs uint, reason syscall.Errno, dest keyctl.Keyring) error
+}{
+	assumeAuthority: keyctl.AssumeAuthority,
+	info: func(key int32) (keyctl.Info, error) {
+		ref := keyctl.Reference{Id: key}
+		return ref.Info()
+	},
+	calloutInfo:    keyctl.CalloutInfo,
+	instantiate:    keyctl.Instantiate,
+	instantiateIov: keyctl.InstantiateIov,
+	negate:         keyctl.Negate,
+	reject:         keyctl.Reject,
+}
+
+// Request describes a key the kernel has asked to be constructed.
+type Request struct {
+	// Operation requested by the kernel, normally "create".
+	Op string
+	// Serial number of the key under construction.
+	Key int32
+	// Type and description of the key under construction, filled in by
+	// Serve() after authority has been assumed.
+	Type, Description string
+	// Callout info passed to request_key(2), if any.
+	CalloutInfo []byte
+	// Credentials of the process which requested the key.
+	Uid, Gid int
+	// Keyrings of the process which requested the key. ThreadKeyring is 0 if
+	// the requesting thread had no thread keyring.
+	ThreadKeyring, ProcessKeyring, SessionKeyring int32
+}
+
+// Parse the command line arguments passed by the kernel to /sbin/request-key.
+// These take the form:
+//
+//	<program> <op> <key> <uid> <gid> <thread-keyring> <process-keyring> <session-keyring>
+func ParseArgs(args []string) (*Request, error) {
+	if len(args) != 8 {
+		return nil, ErrInvalidArgs
+	}
+
+	var ids [6]int64
+	for i, arg := range args[2:] {
+		v, err := strconv.ParseInt(arg, 10, 32)
+		if err != nil {
+			return nil, ErrInvalidArgs
+		}
+		ids[i] = v
+	}
+
+	return &Request{
+		Op:             args[1],
+		Key:            int32(ids[0]),
+		Uid:            int(ids[1]),
+		Gid:            int(ids[2]),
+		ThreadKeyring:  int32(ids[3]),
+		ProcessKeyring: int32(ids[4]),
+		SessionKeyring: int32(ids[5]),
+	}, nil
+}
+
+// Result is the outcome of handling a Request, created by calling Payload(),
+// Negate() or Reject().
+type Result interface {
+	complete(key int32) error
+}
+
+type payloadResult [][]byte
+
+type negateResult uint
+
+type rejectResult struct {
+	nsecs  uint
+	reason syscall.Errno
+}
+
+// Instantiate the requested key with a payload. If more than one buffer is
+// passed, the payload is assembled from all of them by the kernel.
+func Payload(payload ...[]byte) Result {
+	return payloadResult(payload)
+}
+
+// Negatively instantiate the requested key. Requests for the key will fail
+// until nsecs seconds have passed.
+func Negate(nsecs uint) Result {
+	return negateResult(nsecs)
+}
+
+// Reject the requested key. Requests for the key will fail with the given
+// error until nsecs seconds have passed.
+func Reject(nsecs uint, reason syscall.Errno) Result {
+	return rejectResult{nsecs: nsecs, reason: reason}
+}
+
+func (p payloadResult) complete(key int32) error {
+	if len(p) == 1 {
+		return ops.instantiate(key, p[0], nil)
+	}
+	return ops.instantiateIov(key, p, nil)
+}
+
+func (n negateResult) complete(key int32) error {
+	return ops.negate(key, uint(n), nil)
+}
+
+func (r rejectResult) complete(key int32) error {
+	return ops.reject(key, r.nsecs, r.reason, nil)
+}
+
+// A Handler decides how a requested key is constructed. Returning nil is the
+// same as returning Negate(0).
+type Handler func(*Request) Result
+
+// Serve a single request-key invocation. The arguments, normally os.Args, are
+// parsed, authority over the key under construction is assumed and the
+// handler is called with the full details of the request. The key is then
+// completed according to the handler's result.
+func Serve(args []string, h Handler) error {
+	req, err := ParseArgs(args)
+	if err != nil {
+		return err
+	}
+
+	if err = ops.assumeAuthority(req.Key); err != nil {
+		return err
+	}
+	defer ops.assumeAuthority(0)
+
+	info, err := ops.info(req.Key)
+	if err != nil {
+		return err
+	}
+	// Info reports "user" keys as "key".
+	if req.Type, req.Description = info.Type, info.Name; req.Type == "key" {
+		req.Type = keyctl.TypeUser
+	}
+
+	if req.CalloutInfo, err = ops.calloutInfo(); err != nil {
+		return err
+	}
+
+	res := h(req)
+	if res == nil {
+		res = Negate(0)
+	}
+	return res.complete(req.Key)
+}
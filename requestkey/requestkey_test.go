@@ -0,0 +1,137 @@
+package requestkey
+
+import (
+	"bytes"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/jsipprell/keyctl"
+)
+
+var serveArgs = []string{"/sbin/request-key", "create", "123456", "1000", "100", "0", "-2", "654321"}
+
+// Replace the key operations with fakes that record the calls made, restoring
+// the originals when the test completes.
+func helperFakeOps(t *testing.T, calls *[]string) {
+	orig := ops
+	t.Cleanup(func() { ops = orig })
+
+	record := func(format string, args ...interface{}) {
+		*calls = append(*calls, fmt.Sprintf(format, args...))
+	}
+	ops.assumeAuthority = func(key int32) error {
+		record("assume %d", key)
+		return nil
+	}
+	ops.info = func(key int32) (keyctl.Info, error) {
+		return keyctl.Info{Type: "key", Name: "test:description"}, nil
+	}
+	ops.calloutInfo = func() ([]byte, error) {
+		return []byte("callout"), nil
+	}
+	ops.instantiate = func(key int32, payload []byte, dest keyctl.Keyring) error {
+		record("instantiate %d %q", key, payload)
+		return nil
+	}
+	ops.instantiateIov = func(key int32, payload [][]byte, dest keyctl.Keyring) error {
+		record("instantiateIov %d %q", key, bytes.Join(payload, []byte("|")))
+		return nil
+	}
+	ops.negate = func(key int32, nsecs uint, dest keyctl.Keyring) error {
+		record("negate %d %d", key, nsecs)
+		return nil
+	}
+	ops.reject = func(key int32, nsecs uint, reason syscall.Errno, dest keyctl.Keyring) error {
+		record("reject %d %d %v", key, nsecs, reason)
+		return nil
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	req, err := ParseArgs(serveArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Op != "create" || req.Key != 123456 || req.Uid != 1000 || req.Gid != 100 {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if req.ThreadKeyring != 0 || req.ProcessKeyring != -2 || req.SessionKeyring != 654321 {
+		t.Fatalf("unexpected request keyrings %+v", req)
+	}
+}
+
+func TestParseArgsInvalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"/sbin/request-key", "create", "123456"},
+		{"/sbin/request-key", "create", "123456", "1000", "100", "0", "0", "0", "extra"},
+		{"/sbin/request-key", "create", "key", "1000", "100", "0", "0", "0"},
+	} {
+		if _, err := ParseArgs(args); err != ErrInvalidArgs {
+			t.Fatalf("expected ErrInvalidArgs parsing %v, got %v", args, err)
+		}
+	}
+}
+
+func TestServeInvalidArgs(t *testing.T) {
+	err := Serve([]string{"/sbin/request-key"}, func(*Request) Result {
+		t.Fatal("handler called for invalid arguments")
+		return nil
+	})
+	if err != ErrInvalidArgs {
+		t.Fatalf("expected ErrInvalidArgs, got %v", err)
+	}
+}
+
+func TestServe(t *testing.T) {
+	cases := []struct {
+		res      Result
+		expected string
+	}{
+		{Payload([]byte("secret")), `instantiate 123456 "secret"`},
+		{Payload([]byte("sec"), []byte("ret")), `instantiateIov 123456 "sec|ret"`},
+		{nil, "negate 123456 0"},
+		{Negate(30), "negate 123456 30"},
+		{Reject(60, syscall.EKEYREJECTED), fmt.Sprintf("reject 123456 60 %v", syscall.EKEYREJECTED)},
+	}
+
+	for _, c := range cases {
+		var calls []string
+		helperFakeOps(t, &calls)
+
+		err := Serve(serveArgs, func(r *Request) Result {
+			if r.Type != keyctl.TypeUser || r.Description != "test:description" || string(r.CalloutInfo) != "callout" {
+				t.Errorf("unexpected request %+v", r)
+			}
+			return c.res
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{"assume 123456", c.expected, "assume 0"}
+		if fmt.Sprint(calls) != fmt.Sprint(expected) {
+			t.Errorf("expected calls %q, got %q", expected, calls)
+		}
+	}
+}
+
+func TestServeAssumeAuthorityFails(t *testing.T) {
+	var calls []string
+	helperFakeOps(t, &calls)
+	ops.assumeAuthority = func(key int32) error {
+		return syscall.EPERM
+	}
+
+	err := Serve(serveArgs, func(*Request) Result {
+		t.Fatal("handler called without authority")
+		return nil
+	})
+	if err != syscall.EPERM {
+		t.Fatalf("expected EPERM, got %v", err)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("unexpected calls %q", calls)
+	}
+}
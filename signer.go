@@ -0,0 +1,233 @@
+package keyctl
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"io"
+)
+
+var (
+	// Error returned when a certificate's public key does not correspond to
+	// the kernel key it was paired with.
+	ErrKeyMismatch = errors.New("keyctl certificate does not match key")
+	// Error returned when a hash function or signing/decryption option has no
+	// kernel equivalent.
+	ErrUnsupportedAlgorithm = errors.New("keyctl unsupported algorithm")
+	// Error returned when a key used as a PrivateKey holds only the public
+	// half of a key pair and so cannot sign.
+	ErrNotPrivateKey = errors.New("keyctl key is not a private key")
+)
+
+// PrivateKey is an "asymmetric" private key held by the kernel. It implements
+// crypto.Signer and crypto.Decrypter so that it can be used anywhere Go
+// expects a private key (e.g. tls.Certificate.PrivateKey or
+// x509.CreateCertificate) without the key material entering the process.
+type PrivateKey struct {
+	ops *PublicKeyOps
+	pub crypto.PublicKey
+}
+
+// Returns a PrivateKey for an "asymmetric" key, using the DER encoded X.509
+// certificate for the key as the source of its public half. The key is
+// checked by signing a probe digest with it and verifying the signature
+// with the certificate's public key; if they do not match ErrKeyMismatch is
+// returned.
+func NewPrivateKey(key *Key, cert []byte) (*PrivateKey, error) {
+	ops, err := NewPublicKeyOps(key)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := x509.ParseCertificate(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := ops.Query("")
+	if err != nil {
+		return nil, err
+	}
+
+	var bits int
+	switch pub := c.PublicKey.(type) {
+	case *rsa.PublicKey:
+		bits = pub.N.BitLen()
+	case *ecdsa.PublicKey:
+		bits = pub.Curve.Params().BitSize
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if bits != q.KeySize {
+		return nil, ErrKeyMismatch
+	}
+	if q.Supported&PKeySupportsSign == 0 {
+		return nil, ErrNotPrivateKey
+	}
+	if err = checkKeyMatch(c.PublicKey, ops.Sign); err != nil {
+		return nil, err
+	}
+
+	return &PrivateKey{ops: ops, pub: c.PublicKey}, nil
+}
+
+// Digest signed to check that a private key matches a public key.
+var probeDigest = sha256.Sum256([]byte("keyctl private key probe"))
+
+// Check that a probe digest signed with sign verifies with a public key,
+// returning ErrKeyMismatch if it does not.
+func checkKeyMatch(pub crypto.PublicKey, sign func(info string, digest []byte) ([]byte, error)) error {
+	info, err := signInfo(pub, crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	sig, err := sign(info, probeDigest[:])
+	if err != nil {
+		return err
+	}
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, probeDigest[:], sig)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, probeDigest[:], sig) {
+			err = ErrKeyMismatch
+		}
+	}
+	if err != nil {
+		return ErrKeyMismatch
+	}
+	return nil
+}
+
+// Add a DER encoded PKCS#8 private key to a keyring as an "asymmetric" key and
+// return it as a PrivateKey, with the public half taken from the given DER
+// encoded X.509 certificate.
+func AddPrivateKey(ring Keyring, name string, pkcs8, cert []byte) (*PrivateKey, error) {
+	key, err := AddAsymmetric(ring, name, pkcs8)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := NewPrivateKey(key, cert)
+	if err != nil {
+		key.Unlink()
+		return nil, err
+	}
+	return pk, nil
+}
+
+// Returns the key the PrivateKey operates with.
+func (pk *PrivateKey) Key() *Key {
+	return pk.ops.Key()
+}
+
+// Returns the public key corresponding to the private key, taken from its
+// certificate.
+func (pk *PrivateKey) Public() crypto.PublicKey {
+	return pk.pub
+}
+
+// Sign a digest with the private key. RSA keys are signed using PKCS#1 v1.5
+// unless opts is an *rsa.PSSOptions, which must use a salt length of
+// rsa.PSSSaltLengthAuto or rsa.PSSSaltLengthEqualsHash. ECDSA keys produce
+// ASN.1 encoded signatures. The rand argument is unused, randomness is
+// provided by the kernel.
+func (pk *PrivateKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	info, err := signInfo(pk.pub, opts)
+	if err != nil {
+		return nil, err
+	}
+	return pk.ops.Sign(info, digest)
+}
+
+// Decrypt a message with the private key, which must be an RSA key. Only
+// PKCS#1 v1.5 decryption is supported; opts must either be nil or an
+// *rsa.PKCS1v15DecryptOptions.
+//
+// As with rsa.PrivateKey, if opts has a non-zero SessionKeyLen then a key of
+// that length read from rand is returned in place of an error when the
+// message cannot be decrypted to a key of exactly that length, so that
+// protocols such as TLS do not reveal whether the padding was valid.
+func (pk *PrivateKey) Decrypt(rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	if _, ok := pk.pub.(*rsa.PublicKey); !ok {
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	var sessionKeyLen int
+	switch o := opts.(type) {
+	case nil:
+	case *rsa.PKCS1v15DecryptOptions:
+		sessionKeyLen = o.SessionKeyLen
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if sessionKeyLen == 0 {
+		return pk.ops.Decrypt("enc=pkcs1", msg)
+	}
+
+	key := make([]byte, sessionKeyLen)
+	if _, err := io.ReadFull(rand, key); err != nil {
+		return nil, err
+	}
+	if plain, err := pk.ops.Decrypt("enc=pkcs1", msg); err == nil && len(plain) == sessionKeyLen {
+		return plain, nil
+	}
+	return key, nil
+}
+
+var hashNames = map[crypto.Hash]string{
+	crypto.MD4:      "md4",
+	crypto.MD5:      "md5",
+	crypto.SHA1:     "sha1",
+	crypto.SHA224:   "sha224",
+	crypto.SHA256:   "sha256",
+	crypto.SHA384:   "sha384",
+	crypto.SHA512:   "sha512",
+	crypto.SHA3_224: "sha3-224",
+	crypto.SHA3_256: "sha3-256",
+	crypto.SHA3_384: "sha3-384",
+	crypto.SHA3_512: "sha3-512",
+}
+
+// Returns the kernel info string used to sign with a key of the given public
+// key type and signing options.
+func signInfo(pub crypto.PublicKey, opts crypto.SignerOpts) (string, error) {
+	var enc string
+
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		enc = "pkcs1"
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			// The kernel always uses a salt as long as the hash.
+			switch pss.SaltLength {
+			case rsa.PSSSaltLengthAuto, rsa.PSSSaltLengthEqualsHash:
+			default:
+				return "", ErrUnsupportedAlgorithm
+			}
+			enc = "pss"
+		}
+	case *ecdsa.PublicKey:
+		enc = "x962"
+	default:
+		return "", ErrUnsupportedAlgorithm
+	}
+
+	h := opts.HashFunc()
+	if h == 0 || h == crypto.MD5SHA1 {
+		// Unprefixed PKCS#1 v1.5 signatures, as used by TLS 1.0 and 1.1.
+		if enc != "pkcs1" {
+			return "", ErrUnsupportedAlgorithm
+		}
+		return "enc=pkcs1", nil
+	}
+
+	name, ok := hashNames[h]
+	if !ok {
+		return "", ErrUnsupportedAlgorithm
+	}
+	return "enc=" + enc + " hash=" + name, nil
+}
@@ -0,0 +1,216 @@
+package keyctl
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"math/big"
+	"syscall"
+	"testing"
+)
+
+func TestSignInfo(t *testing.T) {
+	rsaKey := &rsa.PublicKey{}
+	ecKey := &ecdsa.PublicKey{}
+
+	for _, c := range []struct {
+		pub  crypto.PublicKey
+		opts crypto.SignerOpts
+		info string
+	}{
+		{rsaKey, crypto.SHA256, "enc=pkcs1 hash=sha256"},
+		{rsaKey, crypto.SHA1, "enc=pkcs1 hash=sha1"},
+		{rsaKey, crypto.MD5SHA1, "enc=pkcs1"},
+		{rsaKey, &rsa.PSSOptions{Hash: crypto.SHA384}, "enc=pss hash=sha384"},
+		{rsaKey, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}, "enc=pss hash=sha256"},
+		{ecKey, crypto.SHA512, "enc=x962 hash=sha512"},
+	} {
+		info, err := signInfo(c.pub, c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info != c.info {
+			t.Fatalf("expected info %q for %T/%v, got %q", c.info, c.pub, c.opts, info)
+		}
+	}
+
+	if _, err := signInfo(ecKey, crypto.MD5SHA1); err != ErrUnsupportedAlgorithm {
+		t.Fatalf("expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+	if _, err := signInfo(rsaKey, crypto.BLAKE2b_256); err != ErrUnsupportedAlgorithm {
+		t.Fatalf("expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+	if _, err := signInfo(rsaKey, &rsa.PSSOptions{SaltLength: 20, Hash: crypto.SHA256}); err != ErrUnsupportedAlgorithm {
+		t.Fatalf("expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+}
+
+func TestPrivateKeySigner(t *testing.T) {
+	priv, der := helperTestCertificate(t)
+
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := AddPrivateKey(ring, "", pkcs8, der)
+	if err != nil {
+		helperSkipUnsupported(t, err)
+		if filterErrno(err, syscall.EBADMSG, syscall.ENOPKG) == nil {
+			t.Skipf("PKCS#8 private keys not supported by this kernel: %v", err)
+		}
+		t.Fatal(err)
+	}
+	defer pk.Key().Unlink()
+
+	var signer crypto.Signer = pk
+	var decrypter crypto.Decrypter = pk
+	if !priv.PublicKey.Equal(signer.Public()) {
+		t.Fatal("public key does not match certificate")
+	}
+
+	digest := make([]byte, 32)
+	rand.Read(digest)
+	sig, err := signer.Sign(rand.Reader, digest, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = rsa.VerifyPKCS1v15(&priv.PublicKey, crypto.SHA256, digest, sig); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := []byte("decrypted by the kernel")
+	enc, err := rsa.EncryptPKCS1v15(rand.Reader, &priv.PublicKey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := decrypter.Decrypt(rand.Reader, enc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	helperCmp(t, dec, msg)
+
+	session := make([]byte, 48)
+	rand.Read(session)
+	if enc, err = rsa.EncryptPKCS1v15(rand.Reader, &priv.PublicKey, session); err != nil {
+		t.Fatal(err)
+	}
+	dec, err = decrypter.Decrypt(rand.Reader, enc, &rsa.PKCS1v15DecryptOptions{SessionKeyLen: 48})
+	if err != nil {
+		t.Fatal(err)
+	}
+	helperCmp(t, dec, session)
+}
+
+func TestPrivateKeyDecryptSessionKey(t *testing.T) {
+	priv, der := helperTestCertificate(t)
+	// The kernel cannot decrypt with only the public half of the key, which
+	// stands in here for a message that fails to decrypt.
+	key := helperAddAsymmetric(t, der)
+	defer key.Unlink()
+	ops, err := NewPublicKeyOps(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := &PrivateKey{ops: ops, pub: &priv.PublicKey}
+
+	enc, err := rsa.EncryptPKCS1v15(rand.Reader, &priv.PublicKey, make([]byte, 48))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pk.Decrypt(rand.Reader, enc, nil); err == nil {
+		t.Fatal("expected decryption with a public key to fail")
+	}
+
+	random := bytes.Repeat([]byte{0xa5}, 48)
+	dec, err := pk.Decrypt(bytes.NewReader(random), enc, &rsa.PKCS1v15DecryptOptions{SessionKeyLen: 48})
+	if err != nil {
+		t.Fatal(err)
+	}
+	helperCmp(t, dec, random)
+}
+
+func TestPrivateKeyMismatch(t *testing.T) {
+	_, der := helperTestCertificate(t)
+	key := helperAddAsymmetric(t, der)
+	defer key.Unlink()
+
+	if _, err := NewPrivateKey(key, der); err != ErrNotPrivateKey {
+		t.Fatalf("expected ErrNotPrivateKey, got %v", err)
+	}
+
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(2)}
+	other, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &ec.PublicKey, ec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewPrivateKey(key, other); err != ErrKeyMismatch {
+		t.Fatalf("expected ErrKeyMismatch, got %v", err)
+	}
+}
+
+func TestPrivateKeyMismatchSameSize(t *testing.T) {
+	priv, der := helperTestCertificate(t)
+	_, other := helperTestCertificate(t)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := helperAddAsymmetric(t, pkcs8)
+	defer key.Unlink()
+
+	if _, err = NewPrivateKey(key, other); err != ErrKeyMismatch {
+		t.Fatalf("expected ErrKeyMismatch, got %v", err)
+	}
+	if _, err = NewPrivateKey(key, der); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckKeyMatch(t *testing.T) {
+	priv, _ := helperTestCertificate(t)
+	other, _ := helperTestCertificate(t)
+	sign := func(info string, digest []byte) ([]byte, error) {
+		if info != "enc=pkcs1 hash=sha256" {
+			t.Fatalf("unexpected info %q", info)
+		}
+		return rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest)
+	}
+
+	if err := checkKeyMatch(&priv.PublicKey, sign); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkKeyMatch(&other.PublicKey, sign); err != ErrKeyMismatch {
+		t.Fatalf("expected ErrKeyMismatch, got %v", err)
+	}
+
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecSign := func(info string, digest []byte) ([]byte, error) {
+		return ecdsa.SignASN1(rand.Reader, ec, digest)
+	}
+	if err = checkKeyMatch(&ec.PublicKey, ecSign); err != nil {
+		t.Fatal(err)
+	}
+	ecOther, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = checkKeyMatch(&ecOther.PublicKey, ecSign); err != ErrKeyMismatch {
+		t.Fatalf("expected ErrKeyMismatch, got %v", err)
+	}
+}
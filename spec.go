@@ -0,0 +1,136 @@
+package keyctl
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Error returned by ParseSpec() and Resolve() when a specifier is malformed,
+// or by Resolve() when it names something other than a keyring.
+var ErrInvalidSpec = errors.New("keyctl invalid key specifier")
+
+// Type of the authorisation key held by request-key helpers.
+const typeRequestKeyAuth = ".request_key_auth"
+
+var specialKeyrings = map[string]keyId{
+	"@t":  keySpecThreadKeyring,
+	"@p":  keySpecProcessKeyring,
+	"@s":  keySpecSessionKeyring,
+	"@u":  keySpecUserKeyring,
+	"@us": keySpecUserSessionKeyring,
+	"@g":  keySpecGroupKeyring,
+	"@a":  keySpecReqKeyAuthKey,
+}
+
+// Parse a key specifier in the form used by the keyctl(1) command and return
+// the key or keyring it refers to. A specifier is one of:
+//
+//	@t, @p, @s, @u, @us, @g  the thread, process, session, user,
+//	                         user-session or group keyring
+//	@a                       the authorisation key of a request-key helper
+//	<id>                     a numeric key id, decimal or (with 0x) hex
+//	%<type>:<description>    a key of the given type found by searching the
+//	                         process's keyrings; "%:<name>" finds a keyring
+//
+// optionally followed by a slash separated path of keyring names, such as
+// "@us/app/db", each of which is opened with OpenKeyring() from the keyring
+// before it. Descriptions containing a slash therefore cannot be used in a
+// specifier.
+func ParseSpec(spec string) (Id, error) {
+	elems := strings.Split(spec, "/")
+	for _, elem := range elems {
+		if elem == "" {
+			return nil, ErrInvalidSpec
+		}
+	}
+
+	id, err := parseSpecElem(elems[0])
+	if err != nil || len(elems) == 1 {
+		return id, err
+	}
+
+	ring, ok := id.(Keyring)
+	if !ok {
+		return nil, ErrInvalidSpec
+	}
+	var nkr NamedKeyring
+	for _, name := range elems[1:] {
+		if nkr, err = OpenKeyring(ring, name); err != nil {
+			return nil, err
+		}
+		ring = nkr
+	}
+	return nkr, nil
+}
+
+// Return the keyring referred to by a key specifier, see ParseSpec().
+func Resolve(spec string) (Keyring, error) {
+	id, err := ParseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	ring, ok := id.(Keyring)
+	if !ok {
+		return nil, ErrInvalidSpec
+	}
+	return ring, nil
+}
+
+func parseSpecElem(elem string) (Id, error) {
+	switch elem[0] {
+	case '@':
+		id, ok := specialKeyrings[elem]
+		if !ok {
+			return nil, ErrInvalidSpec
+		}
+		if id == keySpecReqKeyAuthKey {
+			return &Key{id: id, typ: typeRequestKeyAuth}, nil
+		}
+		return newKeyring(id)
+	case '%':
+		i := strings.IndexByte(elem, ':')
+		if i < 0 || i == len(elem)-1 {
+			return nil, ErrInvalidSpec
+		}
+		keyType, desc := elem[1:i], elem[i+1:]
+		if keyType == "" {
+			keyType = "keyring"
+		}
+		// Without callout info nothing is constructed, only found.
+		key, err := RequestKey(keyType, desc, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if keyType == "keyring" {
+			return &namedKeyring{keyring: &keyring{id: key.id}, name: desc}, nil
+		}
+		return key, nil
+	}
+
+	n, err := strconv.ParseInt(elem, 0, 32)
+	if err != nil || n == 0 {
+		return nil, ErrInvalidSpec
+	}
+	ref := Reference{Id: int32(n)}
+	return ref.Get()
+}
+
+// Return the path of a keyring for use in the path of a NamedKeyring linked
+// to it.
+func pathOf(kr Keyring) string {
+	if nkr, ok := kr.(NamedKeyring); ok {
+		return nkr.Path()
+	}
+	return idSpec(keyId(kr.Id()))
+}
+
+// Return the specifier for a key id, symbolic for the special keyrings.
+func idSpec(id keyId) string {
+	for spec, sid := range specialKeyrings {
+		if id == sid {
+			return spec
+		}
+	}
+	return strconv.Itoa(int(id))
+}
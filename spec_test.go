@@ -0,0 +1,121 @@
+package keyctl
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestParseSpecSpecial(t *testing.T) {
+	for spec, id := range specialKeyrings {
+		switch id {
+		case keySpecThreadKeyring, keySpecProcessKeyring, keySpecReqKeyAuthKey, keySpecGroupKeyring:
+			// Not created for the tests, or in the case of group keyrings,
+			// not implemented by the kernel.
+			continue
+		}
+		ring, err := Resolve(spec)
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		if keyId(ring.Id()) != id {
+			t.Errorf("%s: expected id %d, got %d", spec, id, ring.Id())
+		}
+		if s := idSpec(id); s != spec {
+			t.Errorf("idSpec(%d) = %q, expected %q", id, s, spec)
+		}
+	}
+
+	key, err := ParseSpec("@a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k, ok := key.(*Key); !ok || k.id != keySpecReqKeyAuthKey {
+		t.Fatalf("expected authorisation key from @a, got %v", key)
+	}
+}
+
+func TestParseSpecPath(t *testing.T) {
+	outer := helperTestCreateKeyring(nil, "spectest", t)
+	defer UnlinkKeyring(outer)
+	inner := helperTestCreateKeyring(outer, "inner", t)
+
+	if p := inner.Path(); p != "@s/spectest/inner" {
+		t.Fatalf("unexpected path %q", p)
+	}
+	ring, err := Resolve(inner.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ring.Id() != inner.Id() {
+		t.Fatalf("expected keyring %v from %q, got %v", inner.Id(), inner.Path(), ring.Id())
+	}
+	if p := ring.(NamedKeyring).Path(); p != inner.Path() {
+		t.Fatalf("expected path %q, got %q", inner.Path(), p)
+	}
+
+	for _, spec := range []string{
+		strconv.Itoa(int(inner.Id())),
+		"0x" + strconv.FormatInt(int64(inner.Id()), 16),
+		"%:inner",
+		strconv.Itoa(int(outer.Id())) + "/inner",
+		"%:spectest/inner",
+	} {
+		ring, err := Resolve(spec)
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		if ring.Id() != inner.Id() {
+			t.Errorf("%s: expected keyring %v, got %v", spec, inner.Id(), ring.Id())
+		}
+	}
+
+	if _, err = Move(inner, outer, helperSessionKeyring(t), false); err != nil {
+		helperSkipUnsupported(t, err)
+		t.Fatal(err)
+	}
+	defer UnlinkKeyring(inner)
+	if p := inner.Path(); p != "@s/inner" {
+		t.Fatalf("expected path to follow move, got %q", p)
+	}
+}
+
+func TestParseSpecKey(t *testing.T) {
+	ring := helperTestCreateKeyring(nil, "speckeys", t)
+	defer UnlinkKeyring(ring)
+
+	key, err := ring.Add("spec-key", []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := ParseSpec("%user:spec-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Id() != key.Id() {
+		t.Fatalf("expected key %v, got %v", key.Id(), id.Id())
+	}
+	if _, err = Resolve("%user:spec-key"); !errors.Is(err, ErrInvalidSpec) {
+		t.Fatalf("expected ErrInvalidSpec resolving key as keyring, got %v", err)
+	}
+	if _, err = ParseSpec("%user:spec-missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestParseSpecInvalid(t *testing.T) {
+	for _, spec := range []string{"", "@x", "@s/", "@s//a", "%user", "%user:", "abc", "0", "/a"} {
+		if _, err := ParseSpec(spec); !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("%q: expected ErrInvalidSpec, got %v", spec, err)
+		}
+	}
+}
+
+func helperSessionKeyring(t *testing.T) Keyring {
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
@@ -37,8 +37,67 @@ const (
 	keyctlSetReqKeyKeyring
 	keyctlSetTimeout
 	keyctlAssumeAuthority
+	keyctlGetSecurity
+	keyctlSessionToParent
+	keyctlReject
+	keyctlInstantiateIov
+	keyctlInvalidate
+	keyctlGetPersistent
+	keyctlDHCompute
+	keyctlPKeyQuery
+	keyctlPKeyEncrypt
+	keyctlPKeyDecrypt
+	keyctlPKeySign
+	keyctlPKeyVerify
+	keyctlRestrictKeyring
+	keyctlMove
+	keyctlCapabilities
+	keyctlWatchKey
 )
 
+// Pseudo commands identifying the add_key(2) and request_key(2) syscalls,
+// which are not keyctl(2) commands, in errors.
+const (
+	keyctlAddKey keyctlCommand = -1 - iota
+	keyctlRequestKey
+)
+
+const keyctlMoveExcl = 0x1
+
+// struct keyctl_pkey_query
+type pkeyQuery struct {
+	supportedOps uint32
+	keySize      uint32
+	maxDataSize  uint16
+	maxSigSize   uint16
+	maxEncSize   uint16
+	maxDecSize   uint16
+	spare        [10]uint32
+}
+
+// struct keyctl_pkey_params
+type pkeyParams struct {
+	keyId  int32
+	inLen  uint32
+	outLen uint32 // also in2_len for KEYCTL_PKEY_VERIFY
+	spare  [7]uint32
+}
+
+// struct keyctl_dh_params
+type dhParams struct {
+	private int32
+	prime   int32
+	base    int32
+}
+
+// struct keyctl_kdf_params
+type kdfParams struct {
+	hashname     *byte
+	otherinfo    *byte
+	otherinfolen uint32
+	spare        [8]uint32
+}
+
 var debugSyscalls bool
 
 func (id keyId) Id() int32 {
@@ -81,6 +140,42 @@ func (cmd keyctlCommand) String() string {
 		return "keyctlSetTimeout"
 	case keyctlAssumeAuthority:
 		return "keyctlAssumeAuthority"
+	case keyctlGetSecurity:
+		return "keyctlGetSecurity"
+	case keyctlSessionToParent:
+		return "keyctlSessionToParent"
+	case keyctlReject:
+		return "keyctlReject"
+	case keyctlInstantiateIov:
+		return "keyctlInstantiateIov"
+	case keyctlInvalidate:
+		return "keyctlInvalidate"
+	case keyctlGetPersistent:
+		return "keyctlGetPersistent"
+	case keyctlDHCompute:
+		return "keyctlDHCompute"
+	case keyctlPKeyQuery:
+		return "keyctlPKeyQuery"
+	case keyctlPKeyEncrypt:
+		return "keyctlPKeyEncrypt"
+	case keyctlPKeyDecrypt:
+		return "keyctlPKeyDecrypt"
+	case keyctlPKeySign:
+		return "keyctlPKeySign"
+	case keyctlPKeyVerify:
+		return "keyctlPKeyVerify"
+	case keyctlRestrictKeyring:
+		return "keyctlRestrictKeyring"
+	case keyctlMove:
+		return "keyctlMove"
+	case keyctlCapabilities:
+		return "keyctlCapabilities"
+	case keyctlWatchKey:
+		return "keyctlWatchKey"
+	case keyctlAddKey:
+		return "add_key"
+	case keyctlRequestKey:
+		return "request_key"
 	}
 	panic("bad arg")
 }
@@ -88,15 +183,162 @@ func (cmd keyctlCommand) String() string {
 func keyctl_SetTimeout(id keyId, nsecs uint) error {
 	_, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlSetTimeout), uintptr(id), uintptr(nsecs))
 	if errno != 0 {
-		return errno
+		return newKeyctlError(keyctlSetTimeout, id, errno)
+	}
+	return nil
+}
+
+func keyctl_Revoke(id keyId) error {
+	_, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlRevoke), uintptr(id), 0)
+	if errno != 0 {
+		return newKeyctlError(keyctlRevoke, id, errno)
+	}
+	return nil
+}
+
+func keyctl_Clear(id keyId) error {
+	_, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlClear), uintptr(id), 0)
+	if errno != 0 {
+		return newKeyctlError(keyctlClear, id, errno)
+	}
+	return nil
+}
+
+func keyctl_Invalidate(id keyId) error {
+	_, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlInvalidate), uintptr(id), 0)
+	if errno != 0 {
+		return newKeyctlError(keyctlInvalidate, id, errno)
+	}
+	return nil
+}
+
+func keyctl_Instantiate(id keyId, payload []byte, ring keyId) error {
+	var pptr unsafe.Pointer
+
+	if len(payload) > 0 {
+		pptr = unsafe.Pointer(&payload[0])
+	}
+	_, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlInstantiate), uintptr(id), uintptr(pptr), uintptr(len(payload)), uintptr(ring), 0)
+	if errno != 0 {
+		return newKeyctlError(keyctlInstantiate, id, errno)
+	}
+	return nil
+}
+
+func keyctl_InstantiateIov(id keyId, payload [][]byte, ring keyId) error {
+	var iptr unsafe.Pointer
+
+	iov := make([]syscall.Iovec, 0, len(payload))
+	for _, b := range payload {
+		if len(b) > 0 {
+			v := syscall.Iovec{Base: &b[0]}
+			v.SetLen(len(b))
+			iov = append(iov, v)
+		}
+	}
+	if len(iov) > 0 {
+		iptr = unsafe.Pointer(&iov[0])
+	}
+	_, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlInstantiateIov), uintptr(id), uintptr(iptr), uintptr(len(iov)), uintptr(ring), 0)
+	if errno != 0 {
+		return newKeyctlError(keyctlInstantiateIov, id, errno)
+	}
+	return nil
+}
+
+func keyctl_Negate(id keyId, nsecs uint, ring keyId) error {
+	_, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlNegate), uintptr(id), uintptr(nsecs), uintptr(ring), 0, 0)
+	if errno != 0 {
+		return newKeyctlError(keyctlNegate, id, errno)
+	}
+	return nil
+}
+
+func keyctl_Reject(id keyId, nsecs uint, reason syscall.Errno, ring keyId) error {
+	_, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlReject), uintptr(id), uintptr(nsecs), uintptr(reason), uintptr(ring), 0)
+	if errno != 0 {
+		return newKeyctlError(keyctlReject, id, errno)
 	}
 	return nil
 }
 
+func keyctl_AssumeAuthority(id keyId) error {
+	_, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlAssumeAuthority), uintptr(id), 0)
+	if errno != 0 {
+		return newKeyctlError(keyctlAssumeAuthority, id, errno)
+	}
+	return nil
+}
+
+func keyctl_SetReqKeyKeyring(dest int) (int, error) {
+	r1, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlSetReqKeyKeyring), uintptr(dest), 0)
+	if errno != 0 {
+		return -1, newKeyctlError(keyctlSetReqKeyKeyring, 0, errno)
+	}
+	return int(int32(r1)), nil
+}
+
+func keyctl_PKeyQuery(id keyId, info string) (*pkeyQuery, error) {
+	var (
+		q   pkeyQuery
+		b1  *byte
+		err error
+	)
+
+	if b1, err = syscall.BytePtrFromString(info); err != nil {
+		return nil, err
+	}
+	_, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlPKeyQuery), uintptr(id), 0, uintptr(unsafe.Pointer(b1)), uintptr(unsafe.Pointer(&q)), 0)
+	if errno != 0 {
+		return nil, newKeyctlError(keyctlPKeyQuery, id, errno)
+	}
+	return &q, nil
+}
+
+// Performs one of the KEYCTL_PKEY_ENCRYPT, DECRYPT, SIGN or VERIFY operations.
+// For VERIFY, out is the signature to be verified rather than an output
+// buffer.
+func keyctl_PKeyOp(cmd keyctlCommand, id keyId, info string, in, out []byte) (int, error) {
+	var (
+		b1         *byte
+		err        error
+		iptr, optr unsafe.Pointer
+	)
+
+	if b1, err = syscall.BytePtrFromString(info); err != nil {
+		return -1, err
+	}
+	if len(in) > 0 {
+		iptr = unsafe.Pointer(&in[0])
+	}
+	if len(out) > 0 {
+		optr = unsafe.Pointer(&out[0])
+	}
+	params := pkeyParams{keyId: int32(id), inLen: uint32(len(in)), outLen: uint32(len(out))}
+	r1, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(cmd), uintptr(unsafe.Pointer(&params)), uintptr(unsafe.Pointer(b1)), uintptr(iptr), uintptr(optr), 0)
+	if errno != 0 {
+		return -1, newKeyctlError(cmd, id, errno)
+	}
+	return int(r1), nil
+}
+
+func keyctl_DHCompute(params *dhParams, b []byte, kdf *kdfParams) (int, error) {
+	var bptr unsafe.Pointer
+
+	if len(b) > 0 {
+		bptr = unsafe.Pointer(&b[0])
+	}
+	r1, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlDHCompute), uintptr(unsafe.Pointer(params)), uintptr(bptr), uintptr(len(b)), uintptr(unsafe.Pointer(kdf)), 0)
+	if errno != 0 {
+		return -1, newKeyctlError(keyctlDHCompute, keyId(params.private), errno)
+	}
+	return int(r1), nil
+}
+
 func keyctl_Read(id keyId, b *byte, size int) (int32, error) {
 	v1, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlRead), uintptr(id), uintptr(unsafe.Pointer(b)), uintptr(size), 0, 0)
 	if errno != 0 {
-		return -1, errno
+		return -1, newKeyctlError(keyctlRead, id, errno)
 	}
 
 	return int32(v1), nil
@@ -105,7 +347,7 @@ func keyctl_Read(id keyId, b *byte, size int) (int32, error) {
 func keyctl_Link(id, ring keyId) error {
 	_, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlLink), uintptr(id), uintptr(ring))
 	if errno != 0 {
-		return errno
+		return newKeyctlError(keyctlLink, id, errno)
 	}
 	return nil
 }
@@ -113,7 +355,41 @@ func keyctl_Link(id, ring keyId) error {
 func keyctl_Unlink(id, ring keyId) error {
 	_, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlUnlink), uintptr(id), uintptr(ring))
 	if errno != 0 {
-		return errno
+		return newKeyctlError(keyctlUnlink, id, errno)
+	}
+	return nil
+}
+
+func keyctl_Move(id, from, to keyId, flags uint) error {
+	_, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlMove), uintptr(id), uintptr(from), uintptr(to), uintptr(flags), 0)
+	if errno != 0 {
+		return newKeyctlError(keyctlMove, id, errno)
+	}
+	return nil
+}
+
+func keyctl_WatchKey(id keyId, fd int, watchId int) error {
+	_, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlWatchKey), uintptr(id), uintptr(fd), uintptr(watchId), 0, 0)
+	if errno != 0 {
+		return newKeyctlError(keyctlWatchKey, id, errno)
+	}
+	return nil
+}
+
+func keyctl_Capabilities(b []byte) (int, error) {
+	r1, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlCapabilities), uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)))
+	if errno != 0 {
+		return -1, newKeyctlError(keyctlCapabilities, 0, errno)
+	}
+	return int(r1), nil
+}
+
+// Calls a keyctl command with all arguments zero, used to detect whether the
+// kernel supports the command at all.
+func keyctl_Probe(cmd keyctlCommand) error {
+	_, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(cmd), 0, 0, 0, 0, 0)
+	if errno != 0 {
+		return newKeyctlError(cmd, 0, errno)
 	}
 	return nil
 }
@@ -121,7 +397,7 @@ func keyctl_Unlink(id, ring keyId) error {
 func keyctl_Chown(id keyId, user, group int) error {
 	_, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlChown), uintptr(id), uintptr(user), uintptr(group), 0, 0)
 	if errno != 0 {
-		return errno
+		return newKeyctlError(keyctlChown, id, errno)
 	}
 	return nil
 }
@@ -129,7 +405,7 @@ func keyctl_Chown(id keyId, user, group int) error {
 func keyctl_SetPerm(id keyId, perm uint32) error {
 	_, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlSetPerm), uintptr(id), uintptr(perm))
 	if errno != 0 {
-		return errno
+		return newKeyctlError(keyctlSetPerm, id, errno)
 	}
 	return nil
 }
@@ -163,9 +439,44 @@ func add_key(keyType, keyDesc string, payload []byte, id int32) (int32, error) {
 		0)
 
 	if errno != 0 {
-		err = errno
+		return 0, newKeyctlError(keyctlAddKey, keyId(id), errno)
+	}
+	return int32(r1), nil
+}
+
+func request_key(keyType, keyDesc string, callout []byte, id int32) (int32, error) {
+	var (
+		err        error
+		errno      syscall.Errno
+		b1, b2, b3 *byte
+		r1         uintptr
+	)
+
+	if b1, err = syscall.BytePtrFromString(keyType); err != nil {
+		return 0, err
+	}
+
+	if b2, err = syscall.BytePtrFromString(keyDesc); err != nil {
 		return 0, err
 	}
+
+	if callout != nil {
+		if b3, err = syscall.BytePtrFromString(string(callout)); err != nil {
+			return 0, err
+		}
+	}
+
+	r1, _, errno = syscall.Syscall6(syscall_request_key,
+		uintptr(unsafe.Pointer(b1)),
+		uintptr(unsafe.Pointer(b2)),
+		uintptr(unsafe.Pointer(b3)),
+		uintptr(id),
+		0,
+		0)
+
+	if errno != 0 {
+		return 0, newKeyctlError(keyctlRequestKey, keyId(id), errno)
+	}
 	return int32(r1), nil
 }
 
@@ -188,7 +499,7 @@ func getfsgid() (int32, error) {
 func newKeyring(id keyId) (*keyring, error) {
 	r1, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlGetKeyringId), uintptr(id), uintptr(1))
 	if errno != 0 {
-		return nil, errno
+		return nil, newKeyctlError(keyctlGetKeyringId, id, errno)
 	}
 
 	if id >= 0 {
@@ -197,6 +508,74 @@ func newKeyring(id keyId) (*keyring, error) {
 	return &keyring{id: id}, nil
 }
 
+func joinSessionKeyring(name string) (keyId, error) {
+	var (
+		b1  *byte
+		err error
+	)
+
+	if name != "" {
+		if b1, err = syscall.BytePtrFromString(name); err != nil {
+			return 0, err
+		}
+	}
+	r1, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlJoinSessionKeyring), uintptr(unsafe.Pointer(b1)), 0)
+	if errno != 0 {
+		return 0, newKeyctlError(keyctlJoinSessionKeyring, 0, errno)
+	}
+	return keyId(r1), nil
+}
+
+func sessionToParent() error {
+	_, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlSessionToParent), 0, 0)
+	if errno != 0 {
+		return newKeyctlError(keyctlSessionToParent, 0, errno)
+	}
+	return nil
+}
+
+func getPersistent(uid int, dest keyId) (keyId, error) {
+	r1, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlGetPersistent), uintptr(uid), uintptr(dest))
+	if errno != 0 {
+		return 0, newKeyctlError(keyctlGetPersistent, dest, errno)
+	}
+	return keyId(r1), nil
+}
+
+func restrictKeyring(id keyId, keyType, restriction string) error {
+	var (
+		b1, b2 *byte
+		err    error
+	)
+
+	if keyType != "" {
+		if b1, err = syscall.BytePtrFromString(keyType); err != nil {
+			return err
+		}
+		if b2, err = syscall.BytePtrFromString(restriction); err != nil {
+			return err
+		}
+	}
+	_, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlRestrictKeyring), uintptr(id), uintptr(unsafe.Pointer(b1)), uintptr(unsafe.Pointer(b2)), 0, 0)
+	if errno != 0 {
+		return newKeyctlError(keyctlRestrictKeyring, id, errno)
+	}
+	return nil
+}
+
+// Resolve a special keyring id (e.g. keySpecSessionKeyring) to its real
+// serial number without creating the keyring if it does not exist.
+func resolveKeyId(id keyId) (keyId, error) {
+	if id > 0 {
+		return id, nil
+	}
+	r1, _, errno := syscall.Syscall(syscall_keyctl, uintptr(keyctlGetKeyringId), uintptr(id), 0)
+	if errno != 0 {
+		return 0, newKeyctlError(keyctlGetKeyringId, id, errno)
+	}
+	return keyId(r1), nil
+}
+
 func createKeyring(parent keyId, name string) (*keyring, error) {
 	id, err := add_key("keyring", name, nil, int32(parent))
 	if err != nil {
@@ -220,9 +599,9 @@ func searchKeyring(id keyId, name, keyType string) (keyId, error) {
 	}
 	r1, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlSearch), uintptr(id), uintptr(unsafe.Pointer(b1)), uintptr(unsafe.Pointer(b2)), 0, 0)
 	if errno != 0 {
-		err = errno
+		return 0, newKeyctlError(keyctlSearch, id, errno)
 	}
-	return keyId(r1), err
+	return keyId(r1), nil
 }
 
 func describeKeyId(id keyId) ([]byte, error) {
@@ -237,7 +616,7 @@ func describeKeyId(id keyId) ([]byte, error) {
 	for sizeRead > size {
 		r1, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlDescribe), uintptr(id), uintptr(unsafe.Pointer(&b1[0])), uintptr(size), 0, 0)
 		if errno != 0 {
-			return nil, errno
+			return nil, newKeyctlError(keyctlDescribe, id, errno)
 		}
 		if sizeRead = int(r1); sizeRead > size {
 			b1 = make([]byte, sizeRead)
@@ -251,6 +630,35 @@ func describeKeyId(id keyId) ([]byte, error) {
 	return b1[:size-1], nil
 }
 
+func getSecurity(id keyId) (string, error) {
+	var (
+		b1             []byte
+		size, sizeRead int
+	)
+
+	b1 = make([]byte, 64)
+	size = len(b1)
+	sizeRead = size + 1
+	for sizeRead > size {
+		r1, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlGetSecurity), uintptr(id), uintptr(unsafe.Pointer(&b1[0])), uintptr(size), 0, 0)
+		if errno != 0 {
+			return "", newKeyctlError(keyctlGetSecurity, id, errno)
+		}
+		if sizeRead = int(r1); sizeRead > size {
+			b1 = make([]byte, sizeRead)
+			size = sizeRead
+			sizeRead++
+		} else {
+			size = sizeRead
+		}
+	}
+
+	if size > 0 && b1[size-1] == 0 {
+		size--
+	}
+	return string(b1[:size]), nil
+}
+
 func listKeys(id keyId) ([]keyId, error) {
 	var (
 		b1             []byte
@@ -264,7 +672,7 @@ func listKeys(id keyId) ([]keyId, error) {
 	for sizeRead > size {
 		r1, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlRead), uintptr(id), uintptr(unsafe.Pointer(&b1[0])), uintptr(size), 0, 0)
 		if errno != 0 {
-			return nil, errno
+			return nil, newKeyctlError(keyctlRead, id, errno)
 		}
 
 		if sizeRead = int(r1); sizeRead > size {
@@ -290,7 +698,7 @@ func updateKey(id keyId, payload []byte) error {
 	}
 	_, _, errno := syscall.Syscall6(syscall_keyctl, uintptr(keyctlUpdate), uintptr(id), uintptr(unsafe.Pointer(&payload[0])), uintptr(size), 0, 0)
 	if errno != 0 {
-		return errno
+		return newKeyctlError(keyctlUpdate, id, errno)
 	}
 	return nil
 }
@@ -1,7 +1,8 @@
 package keyctl
 
 const (
-	syscall_keyctl   uintptr = 288
-	syscall_add_key  uintptr = 286
-	syscall_setfsgid uintptr = 139
+	syscall_keyctl      uintptr = 288
+	syscall_add_key     uintptr = 286
+	syscall_request_key uintptr = 287
+	syscall_setfsgid    uintptr = 139
 )
@@ -1,7 +1,8 @@
 package keyctl
 
 const (
-	syscall_keyctl   uintptr = 250
-	syscall_add_key  uintptr = 248
-	syscall_setfsgid uintptr = 123
+	syscall_keyctl      uintptr = 250
+	syscall_add_key     uintptr = 248
+	syscall_request_key uintptr = 249
+	syscall_setfsgid    uintptr = 123
 )
@@ -1,7 +1,8 @@
 package keyctl
 
 const (
-	syscall_keyctl   uintptr = 311
-	syscall_add_key  uintptr = 309
-	syscall_setfsgid uintptr = 139
+	syscall_keyctl      uintptr = 311
+	syscall_add_key     uintptr = 309
+	syscall_request_key uintptr = 310
+	syscall_setfsgid    uintptr = 139
 )
@@ -0,0 +1,273 @@
+package keyctl
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// Error returned by NewWatcher() when the kernel was built without
+// notification support (CONFIG_WATCH_QUEUE and CONFIG_KEY_NOTIFICATIONS).
+var ErrWatchQueueUnsupported = errors.New("keyctl key notifications not supported by kernel")
+
+const (
+	// O_NOTIFICATION_PIPE
+	oNotificationPipe = syscall.O_EXCL
+
+	// _IO('W', 0x60), IOC_WATCH_QUEUE_SET_SIZE
+	iocWatchQueueSetSize = 0x5760
+
+	// Number of notifications the queue can hold before some are lost.
+	watchQueueSize = 256
+
+	// Arbitrary tag passed to the kernel with every watch.
+	watchTag = 0x01
+
+	watchTypeMeta      = 0
+	watchTypeKeyNotify = 1
+
+	watchMetaRemoval = 0
+	watchMetaLoss    = 1
+
+	watchInfoLength = 0x7f
+)
+
+// EventType identifies what happened to a watched key or keyring.
+type EventType int
+
+const (
+	// A key was instantiated.
+	EventInstantiated EventType = iota
+	// A key's payload was updated.
+	EventUpdated
+	// A key was linked to a watched keyring. Event.Aux holds the key linked.
+	EventLinked
+	// A key was unlinked from a watched keyring. Event.Aux holds the key
+	// unlinked.
+	EventUnlinked
+	// A watched keyring was cleared.
+	EventCleared
+	// A key was revoked.
+	EventRevoked
+	// A key was invalidated.
+	EventInvalidated
+	// A key's attributes (permissions, ownership, expiry) were changed.
+	EventSetattr
+	// A watch was removed because the key was destroyed or Unwatch() was
+	// called.
+	EventRemoved
+	// Notifications were lost because the queue overflowed.
+	EventLost
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventInstantiated:
+		return "instantiated"
+	case EventUpdated:
+		return "updated"
+	case EventLinked:
+		return "linked"
+	case EventUnlinked:
+		return "unlinked"
+	case EventCleared:
+		return "cleared"
+	case EventRevoked:
+		return "revoked"
+	case EventInvalidated:
+		return "invalidated"
+	case EventSetattr:
+		return "setattr"
+	case EventRemoved:
+		return "removed"
+	case EventLost:
+		return "lost"
+	}
+	return "EventType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// A notification about a change to a watched key or keyring.
+type Event struct {
+	Type EventType
+	// The key or keyring the event applies to.
+	Key int32
+	// For EventLinked and EventUnlinked events, the key linked or unlinked.
+	Aux int32
+}
+
+// Watcher delivers notifications of changes to keys and keyrings as they
+// happen, using a kernel watch queue.
+type Watcher struct {
+	events chan Event
+	done   chan struct{}
+	file   *os.File
+
+	mu       sync.Mutex
+	fd, wfd  int
+	closed   bool
+	closeErr error
+	err      error
+}
+
+// Create a new Watcher. Keys and keyrings are watched by calling Watch(), and
+// events are delivered on the channel returned by Events() until the Watcher
+// is closed or the context is cancelled.
+func NewWatcher(ctx context.Context) (*Watcher, error) {
+	fds := make([]int, 2)
+	err := syscall.Pipe2(fds, oNotificationPipe|syscall.O_NONBLOCK|syscall.O_CLOEXEC)
+	if err == syscall.ENOPKG || err == syscall.EINVAL {
+		return nil, ErrWatchQueueUnsupported
+	} else if err != nil {
+		return nil, err
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fds[0]), iocWatchQueueSetSize, watchQueueSize)
+	if errno != 0 {
+		syscall.Close(fds[0])
+		syscall.Close(fds[1])
+		if errno == syscall.ENOTTY || errno == syscall.EINVAL {
+			return nil, ErrWatchQueueUnsupported
+		}
+		return nil, errno
+	}
+
+	w := &Watcher{
+		events: make(chan Event),
+		done:   make(chan struct{}),
+		fd:     fds[0],
+		wfd:    fds[1],
+		file:   os.NewFile(uintptr(fds[0]), "watch_queue"),
+	}
+	go w.read()
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.Close()
+		case <-w.done:
+		}
+	}()
+	return w, nil
+}
+
+// Returns the channel events are delivered on. The channel is closed when
+// the Watcher is closed or encounters an error, see Err().
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Returns the error, if any, that caused the Watcher to stop delivering
+// events.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Start watching a key or keyring for changes.
+func (w *Watcher) Watch(k Id) error {
+	return w.watch(k, watchTag)
+}
+
+// Stop watching a key or keyring. An EventRemoved event is delivered once the
+// watch has been removed.
+func (w *Watcher) Unwatch(k Id) error {
+	return w.watch(k, -1)
+}
+
+func (w *Watcher) watch(k Id, tag int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+	return keyctl_WatchKey(keyId(k.Id()), w.fd, tag)
+}
+
+// Close the Watcher, removing all watches and closing the events channel.
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.closed {
+		w.closed = true
+		close(w.done)
+		w.closeErr = w.file.Close()
+		syscall.Close(w.wfd)
+	}
+	return w.closeErr
+}
+
+func (w *Watcher) read() {
+	defer close(w.events)
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.mu.Lock()
+				w.err = err
+				w.mu.Unlock()
+				// Nothing more can be read, release the queue rather than
+				// waiting for the caller to call Close().
+				w.Close()
+			}
+			return
+		}
+
+		for _, ev := range decodeEvents(buf[:n]) {
+			select {
+			case w.events <- ev:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// Decodes the notification records read from a watch queue. Records are
+// made up of a struct watch_notification header, optionally followed by type
+// specific data. The header's type and subtype bitfields are laid out for the
+// little-endian architectures this package supports.
+func decodeEvents(b []byte) []Event {
+	var events []Event
+
+	for len(b) >= 8 {
+		hdr := binary.LittleEndian.Uint32(b[0:])
+		info := binary.LittleEndian.Uint32(b[4:])
+		size := int(info & watchInfoLength)
+		if size < 8 || size > len(b) {
+			break
+		}
+		rec := b[:size]
+		b = b[size:]
+
+		switch typ, subtype := hdr&0xffffff, hdr>>24; {
+		case typ == watchTypeKeyNotify && size >= 16:
+			// Subtypes added by later kernels are dropped rather than
+			// mistaken for the meta events numbered after EventSetattr.
+			if subtype > uint32(EventSetattr) {
+				continue
+			}
+			events = append(events, Event{
+				Type: EventType(subtype),
+				Key:  int32(binary.LittleEndian.Uint32(rec[8:])),
+				Aux:  int32(binary.LittleEndian.Uint32(rec[12:])),
+			})
+		case typ == watchTypeMeta && subtype == watchMetaRemoval:
+			ev := Event{Type: EventRemoved}
+			if size >= 16 {
+				ev.Key = int32(binary.LittleEndian.Uint64(rec[8:]))
+			}
+			events = append(events, ev)
+		case typ == watchTypeMeta && subtype == watchMetaLoss:
+			events = append(events, Event{Type: EventLost})
+		}
+	}
+	return events
+}
@@ -0,0 +1,142 @@
+package keyctl
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func helperNotification(typ, subtype uint32, data ...uint32) []byte {
+	b := make([]byte, 8+4*len(data))
+	binary.LittleEndian.PutUint32(b[0:], typ|subtype<<24)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b))|watchTag<<8)
+	for i, v := range data {
+		binary.LittleEndian.PutUint32(b[8+4*i:], v)
+	}
+	return b
+}
+
+func TestDecodeEvents(t *testing.T) {
+	var b []byte
+	b = append(b, helperNotification(watchTypeKeyNotify, uint32(EventLinked), 100, 200)...)
+	b = append(b, helperNotification(watchTypeKeyNotify, uint32(EventRevoked), 200, 0)...)
+	b = append(b, helperNotification(watchTypeMeta, watchMetaLoss)...)
+	b = append(b, helperNotification(watchTypeMeta, watchMetaRemoval, 300, 0)...)
+	b = append(b, helperNotification(watchTypeKeyNotify, uint32(EventSetattr)+1, 400, 0)...)
+	b = append(b, helperNotification(watchTypeKeyNotify, uint32(EventLost), 400, 0)...)
+
+	events := decodeEvents(b)
+	expected := []Event{
+		{Type: EventLinked, Key: 100, Aux: 200},
+		{Type: EventRevoked, Key: 200},
+		{Type: EventLost},
+		{Type: EventRemoved, Key: 300},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i, ev := range events {
+		if ev != expected[i] {
+			t.Fatalf("event %d: expected %+v, got %+v", i, expected[i], ev)
+		}
+	}
+}
+
+func TestWatcherReadError(t *testing.T) {
+	// Reading a directory fails, standing in for a watch queue that breaks.
+	file, err := os.Open(os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Watcher{
+		events: make(chan Event),
+		done:   make(chan struct{}),
+		file:   file,
+		fd:     int(file.Fd()),
+		wfd:    -1,
+	}
+	go w.read()
+
+	if _, ok := <-w.Events(); ok {
+		t.Fatal("expected events channel to be closed")
+	}
+	if w.Err() == nil {
+		t.Fatal("expected read error")
+	}
+	select {
+	case <-w.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher not closed after read error")
+	}
+	if _, err = file.Stat(); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("expected queue file to be closed, got %v", err)
+	}
+}
+
+func helperNextEvent(t *testing.T, w *Watcher, typ EventType) Event {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-w.Events():
+			if !ok {
+				t.Fatalf("watcher closed waiting for %v event: %v", typ, w.Err())
+			}
+			t.Logf("event %v key %v aux %v", ev.Type, ev.Key, ev.Aux)
+			if ev.Type == typ {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %v event", typ)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := NewWatcher(ctx)
+	if err == ErrWatchQueueUnsupported {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	ring := helperTestCreateKeyring(nil, "watchring", t)
+	defer UnlinkKeyring(ring)
+
+	if err = w.Watch(ring); err != nil {
+		helperSkipUnsupported(t, err)
+		t.Fatal(err)
+	}
+
+	key, err := ring.Add("watch-test", []byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := helperNextEvent(t, w, EventLinked); ev.Key != ring.Id() || ev.Aux != key.Id() {
+		t.Fatalf("unexpected link event %+v", ev)
+	}
+
+	if err = w.Watch(key); err != nil {
+		t.Fatal(err)
+	}
+	if err = key.Set([]byte{2}); err != nil {
+		t.Fatal(err)
+	}
+	helperNextEvent(t, w, EventUpdated)
+	if err = key.Revoke(); err != nil {
+		t.Fatal(err)
+	}
+	helperNextEvent(t, w, EventRevoked)
+
+	cancel()
+	for range w.Events() {
+	}
+	if err = w.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
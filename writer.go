@@ -6,6 +6,8 @@ import (
 	"io"
 )
 
+// Flusher streams key data to the kernel, see NewWriter() and CreateWriter().
+// Unlike *Key, a Flusher is not safe for concurrent use.
 type Flusher interface {
 	io.Writer
 	io.Closer
@@ -17,9 +19,11 @@ var ErrStreamClosed = errors.New("keyctl write stream closed")
 
 type writer struct {
 	*bytes.Buffer
-	key    Id
-	name   string
-	closed bool
+	key     Id
+	ring    Keyring
+	name    string
+	keyType string
+	closed  bool
 }
 
 // Close a stream writer. *This or Flush() MUST be called in order to flush
@@ -39,21 +43,40 @@ func (w *writer) Flush() (err error) {
 		switch t := w.key.(type) {
 		case Keyring:
 			var key Id
-			key, err = t.Add(w.name, w.Bytes())
+			key, err = t.AddWithType(w.flushType(), w.name, w.Bytes())
 			if err == nil {
 				w.key = key
 			}
 		case *Key:
-			err = updateKey(t.id, w.Bytes())
-			if err == nil && t.ttl != 0 {
-				err = t.ExpireAfter(uint(t.ttl.Seconds()))
+			if keyType := w.flushType(); w.ring != nil && keyType != t.Type() {
+				// The buffer has outgrown (or shrunk below) the limits of the
+				// key type originally created, replace it with a new key.
+				var key *Key
+				if key, err = w.ring.AddWithType(keyType, w.name, w.Bytes()); err == nil {
+					t.Unlink()
+					w.key = key
+				}
+				return
 			}
+			err = t.Set(w.Bytes())
 		}
 		return
 	}
 	return ErrStreamClosed
 }
 
+// Returns the key type a new key should be created with given the current
+// size of the buffer.
+func (w *writer) flushType() string {
+	if w.keyType != "" {
+		return w.keyType
+	}
+	if w.Len() > MaxUserKeySize {
+		return TypeBigKey
+	}
+	return TypeUser
+}
+
 func setClosed(w *writer) {
 	w.closed = true
 }
@@ -65,6 +88,20 @@ func NewWriter(key *Key) Flusher {
 }
 
 // Create a new key and stream writer with a given name on an open keyring.
+// The key is created as a "user" key unless more than MaxUserKeySize bytes are
+// written, in which case a "big_key" key is created instead.
 func CreateWriter(name string, ring Keyring) (Flusher, error) {
-	return &writer{Buffer: bytes.NewBuffer(make([]byte, 0, 1024)), key: ring, name: name}, nil
+	return &writer{Buffer: bytes.NewBuffer(make([]byte, 0, 1024)), key: ring, ring: ring, name: name}, nil
+}
+
+// Create a new "big_key" key and stream writer with a given name on an open
+// keyring, regardless of how much data is written.
+func CreateBigKeyWriter(name string, ring Keyring) (Flusher, error) {
+	return &writer{
+		Buffer:  bytes.NewBuffer(make([]byte, 0, 1024)),
+		key:     ring,
+		ring:    ring,
+		name:    name,
+		keyType: TypeBigKey,
+	}, nil
 }
@@ -1,6 +1,7 @@
 package keyctl
 
 import (
+	"syscall"
 	"testing"
 )
 
@@ -97,3 +98,84 @@ func TestStreamWriterFlush(t *testing.T) {
 	helperCompareBlock(t, "test218bytestream", blk1, ring)
 	t.Logf("[flushed] compared %d random block key in common session ring: %v", len(blk1), blk1)
 }
+
+func helperSkipUnsupported(t *testing.T, err error) {
+	if filterErrno(err, syscall.ENODEV, syscall.EOPNOTSUPP) == nil {
+		t.Skipf("not supported by this kernel: %v", err)
+	}
+}
+
+func TestStreamWriterBigKey(t *testing.T) {
+	blk1 := helperRandBlock(MaxUserKeySize + 4096)
+
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := CreateWriter("testbigkeystream", ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for b := blk1; len(b) > 0; {
+		n := 1024
+		if n > len(b) {
+			n = len(b)
+		}
+		if _, err = w.Write(b[:n]); err != nil {
+			t.Fatal(err)
+		}
+		b = b[n:]
+	}
+	if err = w.Close(); err != nil {
+		helperSkipUnsupported(t, err)
+		t.Fatal(err)
+	}
+
+	key, err := ring.SearchWithType(TypeBigKey, "testbigkeystream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Unlink()
+
+	blk2, err := key.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	helperCmp(t, blk2, blk1)
+	t.Logf("compared %d random block big_key in common session ring", len(blk1))
+}
+
+func TestStreamWriterForcedBigKey(t *testing.T) {
+	blk1 := helperRandBlock(218)
+
+	ring, err := SessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := CreateBigKeyWriter("testsmallbigkeystream", ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(blk1); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		helperSkipUnsupported(t, err)
+		t.Fatal(err)
+	}
+
+	key, err := ring.SearchWithType(TypeBigKey, "testsmallbigkeystream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Unlink()
+
+	blk2, err := key.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	helperCmp(t, blk2, blk1)
+}